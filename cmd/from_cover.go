@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+var (
+	coverMinStatements int
+	coverRanges        bool
+)
+
+var fromCoverCmd = &cobra.Command{
+	Use:   "from-cover <coverprofile>",
+	Short: "Enable the files covered by a Go coverage profile",
+	Long: `Read a profile written by go test -coverprofile and enable every project file
+with at least one covered block. Run a single test with -coverpkg=./... to
+select just the code it exercises.`,
+	Example: `  go test -run TestBatch -coverpkg=./... -coverprofile=cover.out ./core
+  nearwait from-cover cover.out
+  nearwait from-cover cover.out --min-statements 5 --ranges`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		module, err := core.ReadGoModule(projectDir)
+		if err != nil {
+			return fmt.Errorf("error reading go.mod: %w", err)
+		}
+		profile, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer profile.Close()
+
+		locs, err := module.CoveredLocations(profile, coverMinStatements)
+		if err != nil {
+			return fmt.Errorf("error reading coverage profile: %w", err)
+		}
+
+		generator := newGenerator(logger)
+		locs, err = generator.ResolveLocations(locs)
+		if err != nil {
+			return err
+		}
+		if len(locs) == 0 {
+			return errors.New("no covered files inside the project found in the profile")
+		}
+
+		context := -1
+		if coverRanges {
+			context = 0
+		}
+		return enableSelection(generator, core.LocationRanges(locs, context))
+	},
+}
+
+func init() {
+	fromCoverCmd.Flags().IntVar(&coverMinStatements, "min-statements", 1, "Skip files with fewer covered statements")
+	fromCoverCmd.Flags().BoolVar(&coverRanges, "ranges", false, "Enable only the lines of covered blocks instead of whole files")
+	rootCmd.AddCommand(fromCoverCmd)
+}
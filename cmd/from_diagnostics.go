@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+var diagnosticsContext int
+
+var fromDiagnosticsCmd = &cobra.Command{
+	Use:   "from-diagnostics",
+	Short: "Enable the files of compiler, vet or linter output read from stdin and bundle them",
+	Long: `Read diagnostics such as "path:line:col: message" from go build, go vet,
+golangci-lint or another compiler on stdin, enable the project files they
+refer to and bundle them together with the diagnostics as diagnostics.txt.`,
+	Example: `  go build ./... 2>&1 | nearwait from-diagnostics
+  golangci-lint run 2>&1 | nearwait from-diagnostics --context 10`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		output, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		locs, err := core.ParseDiagnostics(bytes.NewReader(output))
+		if err != nil {
+			return err
+		}
+
+		generator := newGenerator(logger)
+		locs, err = generator.ResolveLocations(locs)
+		if err != nil {
+			return err
+		}
+		if len(locs) == 0 {
+			return errors.New("no diagnostics inside the project found in the input")
+		}
+
+		context := -1
+		if cmd.Flags().Changed("context") {
+			context = diagnosticsContext
+		}
+		if err := enableSelection(generator, core.LocationRanges(locs, context)); err != nil {
+			return err
+		}
+
+		processor, err := newProcessor(logger)
+		if err != nil {
+			return err
+		}
+		// Stdin held the diagnostics, so there is nothing to wait on between batches
+		processor.WithWaitBatch(false)
+		processor.WithVirtualFile(core.DiagnosticsFile, output)
+		return processManifest(logger, processor)
+	},
+}
+
+func init() {
+	fromDiagnosticsCmd.Flags().IntVar(&diagnosticsContext, "context", 0, "Enable only this many lines around each diagnostic instead of whole files")
+	rootCmd.AddCommand(fromDiagnosticsCmd)
+}
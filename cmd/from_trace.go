@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+var traceContext int
+
+var fromTraceCmd = &cobra.Command{
+	Use:   "from-trace",
+	Short: "Enable the files of a Go panic or stack trace read from stdin",
+	Long: `Read a Go panic, goroutine dump or test log from stdin and enable the
+project files its frames point at. Frames in the Go installation, the module
+cache or outside the project root are ignored.`,
+	Example: `  go test ./... 2>&1 | nearwait from-trace
+  nearwait from-trace --context 20 < panic.txt`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		locs, err := core.ParseTrace(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		generator := newGenerator(logger)
+		locs, err = generator.ResolveLocations(locs)
+		if err != nil {
+			return err
+		}
+		if len(locs) == 0 {
+			return errors.New("no frames inside the project found in the trace")
+		}
+
+		context := -1
+		if cmd.Flags().Changed("context") {
+			context = traceContext
+		}
+		return enableSelection(generator, core.LocationRanges(locs, context))
+	},
+}
+
+func init() {
+	fromTraceCmd.Flags().IntVar(&traceContext, "context", 0, "Enable only this many lines around each frame instead of whole files")
+	rootCmd.AddCommand(fromTraceCmd)
+}
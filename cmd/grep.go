@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+var (
+	grepContext    int
+	grepIgnoreCase bool
+	grepDryRun     bool
+	grepReplace    bool
+	grepAdd        bool
+)
+
+var grepCmd = &cobra.Command{
+	Use:   "grep <regexp>",
+	Short: "Enable the files whose contents match a regular expression",
+	Long: `Search every file the manifest would list, honoring excludes, for lines
+matching a Go regular expression and enable the matching files. By default
+the matches are added to the current selection; --replace comments out
+everything else first.`,
+	Example: `  nearwait grep 'ClipboardWriter|ShouldDelay'
+  nearwait grep --dry-run 'func .*Batch'
+  nearwait grep --replace --context 5 'TODO\(.*\)'`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		expr := args[0]
+		if grepIgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+
+		generator := newGenerator(logger)
+		matches, err := generator.Grep(manifestFile, re)
+		if err != nil {
+			return err
+		}
+
+		locs := make([]core.SourceLocation, len(matches))
+		for i, match := range matches {
+			locs[i] = match.Location()
+		}
+		context := -1
+		if cmd.Flags().Changed("context") {
+			context = grepContext
+		}
+		selection := core.LocationRanges(locs, context)
+
+		if grepDryRun {
+			for _, match := range matches {
+				fmt.Printf("%s:%d: %s\n", match.Path, match.Line, match.Text)
+			}
+			fmt.Printf("%d matches in %d files\n", len(matches), len(selection))
+			return nil
+		}
+		if len(matches) == 0 {
+			return errors.New("no files match the pattern")
+		}
+
+		if grepReplace {
+			if _, err := generator.Generate(false, manifestFile); err != nil {
+				return err
+			}
+			if err := generator.DisableAll(manifestFile); err != nil {
+				return err
+			}
+		}
+		return enableSelection(generator, selection)
+	},
+}
+
+func init() {
+	grepCmd.Flags().IntVar(&grepContext, "context", 0, "Enable only the matching lines plus this many lines around them instead of whole files")
+	grepCmd.Flags().BoolVarP(&grepIgnoreCase, "ignore-case", "i", false, "Match case-insensitively")
+	grepCmd.Flags().BoolVar(&grepDryRun, "dry-run", false, "List the matches without changing the manifest")
+	grepCmd.Flags().BoolVar(&grepReplace, "replace", false, "Comment out every other entry so only the matches are enabled")
+	grepCmd.Flags().BoolVar(&grepAdd, "add", false, "Keep the current selection and enable the matches as well (default)")
+	grepCmd.MarkFlagsMutuallyExclusive("replace", "add")
+	rootCmd.AddCommand(grepCmd)
+}
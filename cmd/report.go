@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+// Exit codes of nearwait, listed in the README
+const (
+	exitOK              = 0
+	exitError           = 1
+	exitEmptyManifest   = 2
+	exitOverBudget      = 3
+	exitSecretsFound    = 4
+	exitClipboardFailed = 5
+)
+
+// Run statuses in a report
+const (
+	statusOK                = "ok"
+	statusManifestGenerated = "manifest_generated"
+	statusEmptyManifest     = "empty_manifest"
+	statusOverBudget        = "over_budget"
+	statusSecretsFound      = "secrets_found"
+	statusClipboardFailed   = "clipboard_failed"
+	statusError             = "error"
+)
+
+// exitStatus maps the error of a run to its report status and exit code
+func exitStatus(err error) (string, int) {
+	switch {
+	case err == nil:
+		return statusOK, exitOK
+	case errors.Is(err, core.ErrEmptyManifest):
+		return statusEmptyManifest, exitEmptyManifest
+	case errors.Is(err, core.ErrOverBudget):
+		return statusOverBudget, exitOverBudget
+	case errors.Is(err, core.ErrSecretsFound):
+		return statusSecretsFound, exitSecretsFound
+	case errors.Is(err, core.ErrClipboardFailed):
+		return statusClipboardFailed, exitClipboardFailed
+	}
+	return statusError, exitError
+}
+
+// withFlagHint adds what can be done about err on the command line; the
+// errors of core say nothing about flags or the clipboard
+func withFlagHint(err error) error {
+	switch {
+	case errors.Is(err, core.ErrSecretsFound):
+		return fmt.Errorf("%w; the redacted archive was written but not copied, use --allow-secrets to copy it anyway", err)
+	case errors.Is(err, core.ErrOverBudget):
+		return fmt.Errorf("%w; the archive was written but not copied, enable fewer files or raise --max-tokens", err)
+	}
+	return err
+}
+
+// newReport starts the report of a run over the manifest
+func newReport() (*core.Report, error) {
+	if reportFormat != "json" {
+		return nil, fmt.Errorf("unknown --report format %q (want json)", reportFormat)
+	}
+	report := &core.Report{Root: source}
+	if source == "" {
+		report.Root, _ = filepath.Abs(projectDir)
+	}
+	report.Manifest, _ = filepath.Abs(manifestFile)
+	return report, nil
+}
+
+// writeReport finishes report with the outcome of the run and writes it in
+// the --report format to --report-file, or stdout
+func writeReport(report *core.Report, err error) error {
+	status, code := exitStatus(err)
+	if report.Status == "" || err != nil {
+		report.Status = status
+	}
+	report.ExitCode = code
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if report.Files == nil {
+		report.Files = []core.ReportFile{}
+	}
+	if report.Outputs == nil {
+		report.Outputs = []string{}
+	}
+	if report.Stages == nil {
+		report.Stages = []core.StageTiming{}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if reportFile == "" || reportFile == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(reportFile, data, 0o644)
+}
+
+// reportToStdout reports whether the report takes over stdout, so other
+// messages go to stderr
+func reportToStdout() bool {
+	return reportFormat != "" && (reportFile == "" || reportFile == "-")
+}
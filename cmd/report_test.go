@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus string
+		wantCode   int
+	}{
+		{err: nil, wantStatus: statusOK, wantCode: exitOK},
+		{err: fmt.Errorf("%w from .nearwait.yml", core.ErrEmptyManifest), wantStatus: statusEmptyManifest, wantCode: exitEmptyManifest},
+		{err: fmt.Errorf("%w: about 9 tokens", core.ErrOverBudget), wantStatus: statusOverBudget, wantCode: exitOverBudget},
+		{err: fmt.Errorf("%w in main.go:1", core.ErrSecretsFound), wantStatus: statusSecretsFound, wantCode: exitSecretsFound},
+		{err: fmt.Errorf("%w: no xclip", core.ErrClipboardFailed), wantStatus: statusClipboardFailed, wantCode: exitClipboardFailed},
+		{err: errors.New("boom"), wantStatus: statusError, wantCode: exitError},
+	}
+	for _, tt := range tests {
+		status, code := exitStatus(tt.err)
+		if status != tt.wantStatus || code != tt.wantCode {
+			t.Errorf("exitStatus(%v) = %s, %d, want %s, %d", tt.err, status, code, tt.wantStatus, tt.wantCode)
+		}
+	}
+}
+
+func TestWithFlagHint(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{err: fmt.Errorf("%w in main.go:1", core.ErrSecretsFound), want: "--allow-secrets"},
+		{err: fmt.Errorf("%w: about 9 tokens", core.ErrOverBudget), want: "--max-tokens"},
+	} {
+		err := withFlagHint(tt.err)
+		if !errors.Is(err, tt.err) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("withFlagHint(%v) = %v, want it wrapped with a %s hint", tt.err, err, tt.want)
+		}
+	}
+	if err := errors.New("boom"); withFlagHint(err) != err {
+		t.Error("withFlagHint() changed an error without a hint")
+	}
+}
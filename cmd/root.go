@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -15,6 +17,9 @@ import (
 	"github.com/gkwa/nearwait/internal/logger"
 )
 
+// projectDir is the project the command works on, the working directory
+const projectDir = "."
+
 var (
 	cfgFile      string
 	verbose      bool
@@ -27,6 +32,29 @@ var (
 	noExclude    bool
 	batchKBytes  int64
 	waitBatch    bool
+	binaryMode   string
+	binaryTypes  map[string]string
+	noRedact     bool
+	allowSecrets bool
+	outline      bool
+	order        string
+	depAPI       []string
+	withTests    bool
+	testPatterns []string
+	diffRef      string
+	diffContext  int
+	diffUntrack  bool
+	historyN     int
+	historyBody  bool
+	historyKB    int
+	revs         []string
+	revPrefix    bool
+	source       string
+	projectFS    fs.FS
+	projectRoot  io.Closer
+	maxTokens    int
+	reportFormat string
+	reportFile   string
 )
 
 var rootCmd = &cobra.Command{
@@ -35,56 +63,170 @@ var rootCmd = &cobra.Command{
 	Long:  `Nearwait is a tool that copies project files to the clipboard according to what's specified in a local manifest YAML file.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := LoggerFrom(cmd.Context())
-		generator := core.NewManifestGenerator(logger)
-		generator.WithFS(os.DirFS(".")) // Initialize with default filesystem
-		if len(includes) > 0 {
-			generator.WithIncludes(includes)
+		var report *core.Report
+		if reportFormat != "" {
+			var err error
+			if report, err = newReport(); err != nil {
+				return err
+			}
 		}
-		if noExclude {
-			generator.DisableExcludes()
+		// Flags are valid by now, so failures are not usage errors
+		cmd.SilenceUsage = true
+		err := run(logger, report)
+		if report != nil {
+			if reportErr := writeReport(report, err); reportErr != nil {
+				logger.Error(reportErr, "Failed to write report")
+				if err == nil {
+					err = reportErr
+				}
+			}
 		}
-		isNewManifest, err := generator.Generate(force, manifestFile)
-		if err != nil {
-			logger.Error(err, "Failed to generate manifest")
-			return err
+		return err
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cliLogger.IsZero() {
+			cliLogger = logger.NewConsoleLogger(verbose, logFormat == "json")
 		}
-		if isNewManifest {
-			absPath, _ := filepath.Abs(manifestFile)
-			fmt.Printf("%s generated successfully\n", absPath)
+		ctx := logr.NewContext(context.Background(), cliLogger)
+		cmd.SetContext(ctx)
+
+		if source != "" {
+			fsys, err := core.OpenSourceFS(cliLogger, source)
+			if err != nil {
+				return fmt.Errorf("error opening --source: %w", err)
+			}
+			projectFS = fsys
 			return nil
 		}
-		processor := core.NewManifestProcessor(logger, debug, manifestFile)
-		processor.WithBatchKBytes(batchKBytes)
-		processor.WithWaitBatch(waitBatch)
-		isEmpty, err := processor.Process()
+		fsys, root, err := core.OpenDirFS(projectDir)
 		if err != nil {
-			logger.Error(err, "Failed to process manifest")
-			return err
-		}
-		if isEmpty {
-			absPath, _ := filepath.Abs(manifestFile)
-			fmt.Fprintf(os.Stderr, "Manifest file list is empty from %s\n", absPath)
+			return fmt.Errorf("error opening the project: %w", err)
 		}
+		projectFS, projectRoot = fsys, root
 		return nil
 	},
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		if cliLogger.IsZero() {
-			cliLogger = logger.NewConsoleLogger(verbose, logFormat == "json")
+}
+
+// run generates the manifest, or bundles its enabled files when it exists,
+// recording the run in report when set
+func run(logger logr.Logger, report *core.Report) error {
+	generator := newGenerator(logger)
+	isNewManifest, err := generator.Generate(force, manifestFile)
+	if err != nil {
+		logger.Error(err, "Failed to generate manifest")
+		return err
+	}
+	if isNewManifest {
+		absPath, _ := filepath.Abs(manifestFile)
+		fmt.Fprintf(console(), "%s generated successfully\n", absPath)
+		if report != nil {
+			report.Status = statusManifestGenerated
+			report.Outputs = append(report.Outputs, absPath)
 		}
-		ctx := logr.NewContext(context.Background(), cliLogger)
-		cmd.SetContext(ctx)
-	},
+		return nil
+	}
+	processor, err := newProcessor(logger)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		processor.WithReport(report)
+	}
+	return processManifest(logger, processor)
+}
+
+// processManifest bundles the enabled files; an empty manifest fails with
+// core.ErrEmptyManifest
+func processManifest(logger logr.Logger, processor *core.ManifestProcessor) error {
+	isEmpty, err := processor.Process()
+	if err != nil {
+		err = withFlagHint(err)
+		logger.Error(err, "Failed to process manifest")
+		return err
+	}
+	if isEmpty {
+		absPath, _ := filepath.Abs(manifestFile)
+		return fmt.Errorf("%w from %s", core.ErrEmptyManifest, absPath)
+	}
+	return nil
+}
+
+// console is where messages meant for the user go: stdout, unless a report
+// is written there
+func console() io.Writer {
+	if reportToStdout() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// newGenerator builds a ManifestGenerator configured from the persistent flags
+func newGenerator(logger logr.Logger) *core.ManifestGenerator {
+	generator := core.NewManifestGenerator(logger)
+	generator.WithFS(projectFS)
+	generator.WithDir(projectDir)
+	if len(includes) > 0 {
+		generator.WithIncludes(includes)
+	}
+	if noExclude {
+		generator.DisableExcludes()
+	}
+	return generator
+}
+
+// newProcessor builds a ManifestProcessor configured from the persistent flags
+func newProcessor(logger logr.Logger) (*core.ManifestProcessor, error) {
+	processor := core.NewManifestProcessor(logger, debug, manifestFile)
+	processor.WithDir(projectDir)
+	processor.WithBatchKBytes(batchKBytes)
+	processor.WithWaitBatch(waitBatch)
+	processor.WithMaxTokens(maxTokens)
+	processor.WithConsole(os.Stdin, console())
+
+	policy, err := core.ParseBinaryPolicy(binaryMode)
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]core.BinaryPolicy, len(binaryTypes))
+	for ext, mode := range binaryTypes {
+		p, err := core.ParseBinaryPolicy(mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --binary-type for %s: %w", ext, err)
+		}
+		policies[ext] = p
+	}
+	processor.WithBinaryPolicy(policy, policies)
+	processor.WithRedaction(!noRedact, allowSecrets)
+	processor.WithOutline(outline)
+
+	strategy, err := core.ParseOrderStrategy(order)
+	if err != nil {
+		return nil, err
+	}
+	processor.WithOrder(strategy)
+	processor.WithDepAPI(depAPI)
+	processor.WithTestPairing(withTests, testPatterns)
+	processor.WithDiff(diffRef, diffContext, diffUntrack)
+	processor.WithHistory(historyN, historyBody, historyKB*1024)
+	processor.WithRevisions(revs, revPrefix)
+	if source != "" {
+		processor.WithFS(projectFS)
+	}
+
+	return processor, nil
 }
 
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		_, code := exitStatus(err)
+		os.Exit(code)
 	}
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	cobra.OnFinalize(closeProject)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.nearwait.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose mode")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "json or text (default is text)")
@@ -95,6 +237,28 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&noExclude, "no-exclude", false, "Disable default directory exclusions")
 	rootCmd.PersistentFlags().Int64VarP(&batchKBytes, "batch-kbytes", "b", 0, "Maximum size of each batch in kilobytes (0 = no batching)")
 	rootCmd.PersistentFlags().BoolVar(&waitBatch, "wait-batch", false, "Wait for user confirmation before copying next batch")
+	rootCmd.PersistentFlags().StringVar(&binaryMode, "binary", "skip", "How to include binary files: skip, base64 or metadata")
+	rootCmd.PersistentFlags().StringToStringVar(&binaryTypes, "binary-type", nil, "Per-extension binary handling, e.g. png=base64,sqlite=metadata")
+	rootCmd.PersistentFlags().StringVar(&order, "order", "", "File order: deps, manifest, lexical or size (default lexical, size for batches)")
+	rootCmd.PersistentFlags().StringSliceVar(&depAPI, "with-dep-api", nil, "Append the exported API of a Go dependency from the local module cache (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&withTests, "with-tests", false, "Also include the tests of enabled sources and the sources of enabled tests")
+	rootCmd.PersistentFlags().StringSliceVar(&testPatterns, "test-pattern", nil, "Test file patterns for --with-tests, * standing for the source name (default *_test.go, test_*.py, *.spec.ts, ...)")
+	rootCmd.PersistentFlags().StringVar(&diffRef, "with-diff", "", "Append changes.diff with the diff of the enabled files against a git ref, e.g. main (HEAD when given without a value)")
+	rootCmd.PersistentFlags().Lookup("with-diff").NoOptDefVal = "HEAD"
+	rootCmd.PersistentFlags().IntVar(&diffContext, "diff-context", core.DefaultDiffContext, "Lines of context in changes.diff")
+	rootCmd.PersistentFlags().BoolVar(&diffUntrack, "diff-untracked", false, "Include untracked files in changes.diff as new files")
+	rootCmd.PersistentFlags().IntVar(&historyN, "with-history", 0, "Append history.txt with the last N commits touching each enabled file")
+	rootCmd.PersistentFlags().BoolVar(&historyBody, "history-body", false, "Include commit message bodies in history.txt")
+	rootCmd.PersistentFlags().IntVar(&historyKB, "history-kbytes", core.DefaultHistoryKBytes, "Maximum size of history.txt in kilobytes (0 = no limit)")
+	rootCmd.PersistentFlags().StringVar(&source, "source", "", "Read the project from a .zip, .tar, .tar.gz or .txtar archive, or a container image (oci:<dir>[:<tag>] or docker-archive:<file>[:<tag>]), instead of the working directory")
+	rootCmd.PersistentFlags().StringSliceVar(&revs, "rev", nil, "Read the enabled files as of this git revision instead of the working copy; . is the working copy (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&revPrefix, "rev-prefix", false, "Place each --rev under a top-level directory named after it")
+	rootCmd.PersistentFlags().BoolVar(&outline, "outline", false, "Render Go files as outlines (signatures and types only) unless their entry says mode: full")
+	rootCmd.PersistentFlags().BoolVar(&noRedact, "no-redact", false, "Disable secret redaction")
+	rootCmd.PersistentFlags().IntVar(&maxTokens, "max-tokens", 0, "Fail when the archive is estimated to hold more tokens than this (0 = no limit)")
+	rootCmd.Flags().StringVar(&reportFormat, "report", "", "Write a machine-readable report of the run: json")
+	rootCmd.Flags().StringVar(&reportFile, "report-file", "-", "Where --report is written, - for stdout")
+	rootCmd.PersistentFlags().BoolVar(&allowSecrets, "allow-secrets", false, "Copy to clipboard even when high-confidence secrets were redacted")
 
 	if err := viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose")); err != nil {
 		fmt.Printf("Error binding verbose flag: %v\n", err)
@@ -127,6 +291,14 @@ func initConfig() {
 	verbose = viper.GetBool("verbose")
 }
 
+// closeProject releases the project directory opened for the command
+func closeProject() {
+	if projectRoot != nil {
+		projectRoot.Close()
+		projectRoot = nil
+	}
+}
+
 func LoggerFrom(ctx context.Context, keysAndValues ...interface{}) logr.Logger {
 	if cliLogger.IsZero() {
 		cliLogger = logger.NewConsoleLogger(verbose, logFormat == "json")
@@ -122,3 +122,20 @@ func TestJSONLogger(t *testing.T) {
 	t.Logf("Log output: %s", logOutput)
 	t.Logf("Version output: %s", versionOutput)
 }
+
+func TestWithDiffDefaultsToHEAD(t *testing.T) {
+	flag := rootCmd.PersistentFlags().Lookup("with-diff")
+	defer func() {
+		diffRef = ""
+		flag.Changed = false
+	}()
+
+	for args, want := range map[string]string{"--with-diff": "HEAD", "--with-diff=main": "main"} {
+		if err := rootCmd.PersistentFlags().Parse([]string{args}); err != nil {
+			t.Fatal(err)
+		}
+		if diffRef != want {
+			t.Errorf("%s sets the ref to %q, want %q", args, diffRef, want)
+		}
+	}
+}
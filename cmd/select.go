@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+var (
+	selectGoDeps      []string
+	selectTests       bool
+	selectDepth       int
+	selectGitChanged  string
+	selectGitStaged   bool
+	selectGitUnstaged bool
+	selectGitCommit   string
+)
+
+var selectCmd = &cobra.Command{
+	Use:   "select",
+	Short: "Enable manifest entries computed from the project",
+	Long: `Enable manifest entries computed from the project instead of editing the
+manifest by hand. Files outside the selection keep their current state.
+Several selectors can be combined; their files are merged.`,
+	Example: `  nearwait select --go-deps ./cmd
+  nearwait select --go-deps ./cmd --tests --depth 1
+  nearwait select --git-changed main
+  nearwait select --git-staged --git-unstaged
+  nearwait select --git-commit HEAD~1`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		gitSelected := selectGitChanged != "" || selectGitStaged || selectGitUnstaged || selectGitCommit != ""
+		if len(selectGoDeps) == 0 && !gitSelected {
+			return errors.New("nothing to select, use --go-deps or one of the --git-* flags")
+		}
+
+		selection := make(map[string][]core.LineRange)
+		add := func(files []string) {
+			for _, file := range files {
+				selection[file] = nil
+			}
+		}
+
+		if len(selectGoDeps) > 0 {
+			module, err := core.ReadGoModule(projectDir)
+			if err != nil {
+				return fmt.Errorf("error reading go.mod: %w", err)
+			}
+			files, err := module.GoDepsClosure(selectGoDeps, selectTests, selectDepth)
+			if err != nil {
+				return err
+			}
+			add(files)
+		}
+
+		if gitSelected {
+			repo, err := core.OpenGitRepo(projectDir)
+			if err != nil {
+				return err
+			}
+			selectors := []struct {
+				enabled bool
+				files   func() ([]string, error)
+			}{
+				{selectGitChanged != "", func() ([]string, error) { return repo.BranchChanges(selectGitChanged) }},
+				{selectGitStaged, repo.StagedChanges},
+				{selectGitUnstaged, repo.UnstagedChanges},
+				{selectGitCommit != "", func() ([]string, error) { return repo.CommitChanges(selectGitCommit) }},
+			}
+			for _, selector := range selectors {
+				if !selector.enabled {
+					continue
+				}
+				files, err := selector.files()
+				if err != nil {
+					return err
+				}
+				add(files)
+			}
+		}
+
+		if len(selection) == 0 {
+			return errors.New("no files selected")
+		}
+		return enableSelection(newGenerator(logger), selection)
+	},
+}
+
+// enableSelection brings the manifest up to date, enables the selected files
+// and reports how many of them were newly enabled
+func enableSelection(generator *core.ManifestGenerator, selection map[string][]core.LineRange) error {
+	for file := range selection {
+		if core.IsOwnFile(manifestFile, file) {
+			delete(selection, file)
+		}
+	}
+	if _, err := generator.Generate(false, manifestFile); err != nil {
+		return err
+	}
+	enabled, err := generator.EnableFileRanges(manifestFile, selection)
+	if err != nil {
+		return err
+	}
+
+	absPath, _ := filepath.Abs(manifestFile)
+	fmt.Printf("Enabled %d of %d selected files in %s\n", enabled, len(selection), absPath)
+	return nil
+}
+
+func init() {
+	selectCmd.Flags().StringSliceVar(&selectGoDeps, "go-deps", nil, "Enable the Go files of these packages and their in-module dependency closure")
+	selectCmd.Flags().BoolVar(&selectTests, "tests", false, "Include _test.go files of the selected packages")
+	selectCmd.Flags().IntVar(&selectDepth, "depth", 0, "Maximum number of import hops to follow (0 = no limit)")
+	selectCmd.Flags().StringVar(&selectGitChanged, "git-changed", "", "Enable files changed since the merge base with this ref, including uncommitted changes")
+	selectCmd.Flags().BoolVar(&selectGitStaged, "git-staged", false, "Enable files with staged changes")
+	selectCmd.Flags().BoolVar(&selectGitUnstaged, "git-unstaged", false, "Enable files with unstaged changes, including untracked files")
+	selectCmd.Flags().StringVar(&selectGitCommit, "git-commit", "", "Enable files changed by this commit")
+	rootCmd.AddCommand(selectCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+var watchDebounce time.Duration
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Keep the manifest and bundle up to date as files change",
+	Long: `Watch the project root and the directories mounted by the manifest for
+changes. Adding or removing files regenerates the manifest; editing an enabled
+file or the manifest re-renders the txtar archive and copies it to the
+clipboard again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+		if source != "" {
+			return fmt.Errorf("watch cannot be combined with --source")
+		}
+
+		// Prompting between batches would block the watch loop
+		processor, err := newProcessor(logger)
+		if err != nil {
+			return err
+		}
+		processor.WithWaitBatch(false)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		watcher := core.NewWatcher(logger, newGenerator(logger), processor, manifestFile)
+		watcher.WithDebounce(watchDebounce)
+		return watcher.Run(ctx)
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 300*time.Millisecond, "Wait for changes to settle for this long before refreshing")
+	rootCmd.AddCommand(watchCmd)
+}
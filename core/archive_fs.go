@@ -0,0 +1,225 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/tools/txtar"
+)
+
+// Archives are loaded into memory, so their size is capped
+const (
+	// maxArchiveBytes caps the total size of the files read from an archive
+	// or image
+	maxArchiveBytes = 1 << 30
+	// maxArchiveFileBytes caps the size of a single file; larger files are
+	// listed but cannot be read
+	maxArchiveFileBytes = 16 << 20
+	// maxLinkHops caps the symlinks followed to resolve a single path
+	maxLinkHops = 40
+)
+
+// errFileTooLarge is returned when opening an archive entry larger than
+// maxArchiveFileBytes
+var errFileTooLarge = fmt.Errorf("file larger than %d MiB in the source was not read", maxArchiveFileBytes>>20)
+
+// OpenArchiveFS serves the contents of a zip, tar, tar.gz or txtar archive
+// as a read-only file system, without unpacking it to disk. Links that lead
+// nowhere inside a tar archive are reported to logger.
+func OpenArchiveFS(logger logr.Logger, name string) (fs.FS, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if strings.HasSuffix(lower, ".tar") {
+			return tarFS(logger, f, name)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+		defer gz.Close()
+		return tarFS(logger, gz, name)
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".txtar"):
+	default:
+		return nil, fmt.Errorf("unsupported archive %s: want .zip, .tar, .tar.gz or .txtar", name)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(lower, ".zip") {
+		fsys, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+		return fsys, nil
+	}
+	fsys, err := txtar.FS(txtar.Parse(data))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", name, err)
+	}
+	return fsys, nil
+}
+
+// tarFS loads the files of a tar stream into memory. Symlinks and hardlinks
+// are served as the files they lead to inside the archive; files larger than
+// maxArchiveFileBytes are listed without their contents.
+func tarFS(logger logr.Logger, r io.Reader, name string) (fs.FS, error) {
+	fsys := newMemFS()
+	links := make(map[string]string)
+	var total int64
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+		// Image layers name files from the root, e.g. /etc/hosts
+		entry := strings.TrimPrefix(header.Name, "/")
+
+		switch header.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			clean, err := cleanName(entry)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", name, err)
+			}
+			target := header.Linkname
+			if header.Typeflag == tar.TypeLink {
+				// Hardlinks name another entry of the archive
+				target = "/" + strings.TrimPrefix(target, "/")
+			}
+			links[clean] = target
+			continue
+		case tar.TypeReg:
+		default:
+			continue
+		}
+
+		mode := header.FileInfo().Mode()
+		if header.Size > maxArchiveFileBytes {
+			file := &memFile{size: header.Size, mode: mode.Perm(), modTime: header.ModTime, err: errFileTooLarge}
+			if err := fsys.put(entry, file); err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", name, err)
+			}
+			continue
+		}
+		if total += header.Size; total > maxArchiveBytes {
+			return nil, fmt.Errorf("error reading %s: it holds more than %d MiB of files", name, maxArchiveBytes>>20)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from %s: %w", header.Name, name, err)
+		}
+		if err := fsys.add(entry, data, mode, header.ModTime); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", name, err)
+		}
+	}
+
+	resolveArchiveLinks(fsys, links)
+	for _, link := range sortedKeys(links) {
+		logger.Info("Skipping link that leads nowhere inside the source", "source", name, "path", link, "target", links[link])
+	}
+	return fsys, nil
+}
+
+// resolveArchiveLinks serves each link as the file or directory it leads to,
+// removing it from links. Links that lead nowhere are left in links.
+func resolveArchiveLinks(fsys *memFS, links map[string]string) {
+	// underLink reports whether an unresolved link lies below dir, in which
+	// case copying dir now would miss the files behind that link
+	underLink := func(dir string) bool {
+		for link := range links {
+			if dir == "." || strings.HasPrefix(link, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Directory links wait for the links below their target until no other
+	// link can be resolved
+	waitForLinks := true
+	for pass := 0; pass < maxLinkHops && len(links) > 0; pass++ {
+		progress := false
+		for _, link := range sortedKeys(links) {
+			target, ok := linkTarget(link, links, 0)
+			if !ok {
+				continue
+			}
+			if file, ok := fsys.files[target]; ok {
+				fsys.put(link, file)
+			} else if _, ok := fsys.dirs[target]; ok && target != "." && !(waitForLinks && underLink(target)) {
+				for _, name := range sortedKeys(fsys.files) {
+					if rest, ok := strings.CutPrefix(name, target+"/"); ok {
+						fsys.put(link+"/"+rest, fsys.files[name])
+					}
+				}
+			} else {
+				continue
+			}
+			delete(links, link)
+			progress = true
+		}
+		switch {
+		case progress:
+			waitForLinks = true
+		case waitForLinks:
+			waitForLinks = false
+		default:
+			return
+		}
+	}
+}
+
+// linkTarget resolves the target of link to a path from the archive root,
+// following the links met on the way. Like a chroot, ".." stops at the root.
+func linkTarget(link string, links map[string]string, hops int) (string, bool) {
+	target := links[link]
+	if !path.IsAbs(target) {
+		target = path.Join("/", path.Dir(link), target)
+	}
+	return expandLinks(strings.TrimPrefix(path.Clean(target), "/"), links, hops+1)
+}
+
+// expandLinks replaces every link in the slash-separated name with its
+// target, giving up after maxLinkHops links
+func expandLinks(name string, links map[string]string, hops int) (string, bool) {
+	if name == "" || name == "." {
+		return ".", true
+	}
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		if _, ok := links[prefix]; !ok {
+			continue
+		}
+		if hops >= maxLinkHops {
+			return "", false
+		}
+		target, ok := linkTarget(prefix, links, hops)
+		if !ok {
+			return "", false
+		}
+		return expandLinks(path.Join(target, strings.Join(parts[i+1:], "/")), links, hops+1)
+	}
+	return name, true
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/tools/txtar"
+)
+
+var archiveTestFiles = map[string]string{
+	"go.mod":        "module example.com/repro\n",
+	"pkg/a.go":      "package pkg\n",
+	"pkg/sub/b.go":  "package sub\n",
+	"testdata/x.md": "notes\n",
+}
+
+func writeTar(t *testing.T, files map[string]string, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	var tw *tar.Writer
+	if compress {
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for name, content := range files {
+		header := &tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compress {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeTxtar(files map[string]string) []byte {
+	var ar txtar.Archive
+	for name, content := range files {
+		ar.Files = append(ar.Files, txtar.File{Name: name, Data: []byte(content)})
+	}
+	return txtar.Format(&ar)
+}
+
+func TestOpenArchiveFS(t *testing.T) {
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+	}{
+		{name: "repro.zip", data: func(t *testing.T) []byte { return writeZip(t, archiveTestFiles) }},
+		{name: "repro.tar", data: func(t *testing.T) []byte { return writeTar(t, archiveTestFiles, false) }},
+		{name: "repro.tar.gz", data: func(t *testing.T) []byte { return writeTar(t, archiveTestFiles, true) }},
+		{name: "repro.tgz", data: func(t *testing.T) []byte { return writeTar(t, archiveTestFiles, true) }},
+		{name: "repro.txtar", data: func(t *testing.T) []byte { return writeTxtar(archiveTestFiles) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, tt.data(t), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			fsys, err := OpenArchiveFS(testLogger(t), path)
+			if err != nil {
+				t.Fatalf("OpenArchiveFS() error = %v", err)
+			}
+
+			var names []string
+			for name, want := range archiveTestFiles {
+				names = append(names, name)
+				got, err := fs.ReadFile(fsys, name)
+				if err != nil {
+					t.Fatalf("ReadFile(%s) error = %v", name, err)
+				}
+				if string(got) != want {
+					t.Errorf("ReadFile(%s) = %q, want %q", name, got, want)
+				}
+			}
+			if err := fstest.TestFS(fsys, names...); err != nil {
+				t.Errorf("TestFS() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenArchiveFSErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			name:    "escape.tar",
+			data:    writeTar(t, map[string]string{"../outside.go": "package x\n"}, false),
+			wantErr: `invalid path "./../outside.go"`,
+		},
+		{
+			name:    "repro.rar",
+			data:    []byte("rar"),
+			wantErr: "unsupported archive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := OpenArchiveFS(testLogger(t), path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("OpenArchiveFS() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTarLinks(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	write := func(header *tar.Header, content string) {
+		t.Helper()
+		header.Size = int64(len(content))
+		if header.Mode == 0 {
+			header.Mode = 0o644
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Links come before their targets, as they may in image layers
+	write(&tar.Header{Name: "/lib", Typeflag: tar.TypeSymlink, Linkname: "usr/lib"}, "")
+	write(&tar.Header{Name: "/etc/localtime", Typeflag: tar.TypeSymlink, Linkname: "../../usr/share/zoneinfo/UTC"}, "")
+	write(&tar.Header{Name: "/etc/dangling", Typeflag: tar.TypeSymlink, Linkname: "/nowhere"}, "")
+	write(&tar.Header{Name: "/etc/loop", Typeflag: tar.TypeSymlink, Linkname: "loop"}, "")
+	write(&tar.Header{Name: "/usr/lib/os-release", Typeflag: tar.TypeReg}, "ID=test\n")
+	write(&tar.Header{Name: "/usr/share/zoneinfo/UTC", Typeflag: tar.TypeReg}, "TZif\n")
+	write(&tar.Header{Name: "/usr/lib/release", Typeflag: tar.TypeSymlink, Linkname: "os-release"}, "")
+	write(&tar.Header{Name: "/usr/bin/sh", Typeflag: tar.TypeReg, Mode: 0o755}, "#!sh\n")
+	write(&tar.Header{Name: "/usr/bin/ash", Typeflag: tar.TypeLink, Linkname: "usr/bin/sh"}, "")
+	write(&tar.Header{Name: "/big.bin", Typeflag: tar.TypeReg}, strings.Repeat("x", maxArchiveFileBytes+1))
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := tarFS(testLogger(t), &buf, "links.tar")
+	if err != nil {
+		t.Fatalf("tarFS() error = %v", err)
+	}
+	want := map[string]string{
+		"etc/localtime":          "TZif\n",
+		"lib/os-release":         "ID=test\n",
+		"lib/release":            "ID=test\n",
+		"usr/lib/release":        "ID=test\n",
+		"usr/bin/ash":            "#!sh\n",
+		"usr/lib/os-release":     "ID=test\n",
+		"usr/share/zoneinfo/UTC": "TZif\n",
+	}
+	for name, content := range want {
+		got, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Errorf("ReadFile(%s) error = %v", name, err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("ReadFile(%s) = %q, want %q", name, got, content)
+		}
+	}
+	for _, name := range []string{"etc/dangling", "etc/loop"} {
+		if _, err := fs.Stat(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(%s) error = %v, want not exist", name, err)
+		}
+	}
+
+	info, err := fs.Stat(fsys, "big.bin")
+	if err != nil || info.Size() != maxArchiveFileBytes+1 {
+		t.Errorf("Stat(big.bin) = %v, %v, want its size", info, err)
+	}
+	if _, err := fs.ReadFile(fsys, "big.bin"); !errors.Is(err, errFileTooLarge) {
+		t.Errorf("ReadFile(big.bin) error = %v, want %v", err, errFileTooLarge)
+	}
+}
@@ -1,10 +1,9 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
-	"os"
-	"path/filepath"
 	"sort"
 
 	"golang.org/x/tools/txtar"
@@ -14,10 +13,14 @@ import (
 type FileInfo struct {
 	Path string
 	Size int64
+	// Note is the size of the line the file adds to the escape note in the
+	// archive comment, 0 when it is not escaped
+	Note int64
 }
 
-// createBatches creates multiple txtar files based on the batch size
-func (mp *ManifestProcessor) createBatches(projectInfo ProjectInfo) ([]string, error) {
+// createBatches renders the staged files into several txtar archives of at
+// most the batch size each
+func (mp *ManifestProcessor) createBatches(ctx context.Context, staged fs.FS) ([][]byte, error) {
 	// If batching is disabled, return
 	if mp.batchKBytes <= 0 {
 		return nil, nil
@@ -28,72 +31,75 @@ func (mp *ManifestProcessor) createBatches(projectInfo ProjectInfo) ([]string, e
 
 	mp.logger.V(1).Info("Creating batched txtar archives",
 		"batch_kbytes", mp.batchKBytes,
-		"batch_bytes", batchBytes,
-		"extract_dir", projectInfo.ExtractDir)
+		"batch_bytes", batchBytes)
 
-	// Get all files and their sizes in the extraction directory
+	rendered, err := mp.renderFiles(ctx, staged)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get all files and their rendered sizes
 	var files []FileInfo
-	err := filepath.Walk(projectInfo.ExtractDir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
+	sections := make(map[string]txtar.File, len(rendered))
+	for _, section := range rendered {
+		size, note := escapedSize(section, len(rendered))
+		files = append(files, FileInfo{
+			Path: section.Name,
+			Size: size,
+			Note: note,
+		})
+		sections[section.Name] = section
+	}
+
+	// Without an explicit order, sort files by size to help distribute large files
+	if mp.order == OrderDefault {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Size > files[j].Size
+		})
+	}
+
+	// The archive comment counts against the batch that carries it: the
+	// paired tests in the first batch, and the escape note in every batch
+	// holding an escaped file
+	commentSize := func(batch int) int64 {
+		if batch == 0 {
+			return int64(len(mp.pairedComment()))
 		}
-		if !info.IsDir() {
-			relPath, err := filepath.Rel(projectInfo.ExtractDir, path)
-			if err != nil {
-				return err
-			}
-			// Skip the .tar file
-			if filepath.Ext(path) == ".tar" {
-				return nil
+		return 0
+	}
+	cost := func(file FileInfo, batch int, hasNote bool) int64 {
+		size := file.Size + file.Note
+		if file.Note > 0 && !hasNote {
+			size += int64(len(escapeCommentHeader))
+			if commentSize(batch) > 0 {
+				size++ // the blank line between the comments
 			}
-			// Account for txtar overhead: each file adds a header line plus a newline
-			// The txtar format adds: "-- filename --\n" + content + "\n"
-			overhead := int64(len("-- "+relPath+" --\n") + 1) // +1 for the trailing newline
-			files = append(files, FileInfo{
-				Path: relPath,
-				Size: info.Size() + overhead,
-			})
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		return size
 	}
 
-	// Sort files by size to help distribute large files
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Size > files[j].Size
-	})
-
 	// Create batches
 	var batches [][]FileInfo
 	var currentBatch []FileInfo
-	var currentSize int64
+	currentSize := commentSize(0)
+	hasNote := false
 
-	// Add each file to a batch
+	// Add each file to a batch; a file larger than the batch size gets a
+	// batch of its own
 	for _, file := range files {
-		// If file is larger than batch size, create its own batch
-		if file.Size > batchBytes {
-			// If a current batch exists, add it and start a new set of batches
-			if len(currentBatch) > 0 {
-				batches = append(batches, currentBatch)
-				currentBatch = []FileInfo{}
-				currentSize = 0
-			}
-			batches = append(batches, []FileInfo{file})
-			continue
-		}
+		size := cost(file, len(batches), hasNote)
 
 		// If adding this file would exceed batch size, start a new batch
-		if currentSize+file.Size > batchBytes && len(currentBatch) > 0 {
+		if currentSize+size > batchBytes && len(currentBatch) > 0 {
 			batches = append(batches, currentBatch)
-			currentBatch = []FileInfo{file}
-			currentSize = file.Size
-		} else {
-			// Add file to current batch
-			currentBatch = append(currentBatch, file)
-			currentSize += file.Size
+			currentBatch = nil
+			currentSize = commentSize(len(batches))
+			hasNote = false
+			size = cost(file, len(batches), hasNote)
 		}
+		currentBatch = append(currentBatch, file)
+		currentSize += size
+		hasNote = hasNote || file.Note > 0
 	}
 
 	// Add the last batch if not empty
@@ -101,36 +107,37 @@ func (mp *ManifestProcessor) createBatches(projectInfo ProjectInfo) ([]string, e
 		batches = append(batches, currentBatch)
 	}
 
-	// Create a txtar file for each batch
-	var batchFiles []string
+	// Create a txtar archive for each batch
+	var batchArchives [][]byte
+	var layout []ReportBatch
 	for i, batch := range batches {
-		batchFileName := filepath.Join(projectInfo.BatchDir, fmt.Sprintf("batch_%03d.txtar", i+1))
-
-		// Create a txtar archive for this batch
 		var ar txtar.Archive
-		for _, file := range batch {
-			fullPath := filepath.Join(projectInfo.ExtractDir, file.Path)
-			content, err := os.ReadFile(fullPath)
-			if err != nil {
-				return nil, err
-			}
-			ar.Files = append(ar.Files, txtar.File{
-				Name: file.Path,
-				Data: content,
-			})
+		if i == 0 {
+			ar.Comment = mp.pairedComment()
 		}
-
-		// Write the batch to a file
-		if err := os.WriteFile(batchFileName, txtar.Format(&ar), 0o644); err != nil {
-			return nil, err
+		for _, file := range batch {
+			ar.Files = append(ar.Files, sections[file.Path])
 		}
+		escapeArchive(&ar)
 
-		mp.logger.V(1).Info("Created batch txtar file",
+		mp.logger.V(1).Info("Created batch txtar archive",
 			"batch", i+1,
-			"file_count", len(batch),
-			"path", batchFileName)
-		batchFiles = append(batchFiles, batchFileName)
+			"file_count", len(batch))
+		data := txtar.Format(&ar)
+		batchArchives = append(batchArchives, data)
+
+		reported := ReportBatch{Name: batchName(i), Bytes: len(data), Tokens: EstimateTokens(data)}
+		for _, file := range batch {
+			reported.Files = append(reported.Files, file.Path)
+		}
+		layout = append(layout, reported)
 	}
+	mp.report.setBatches(layout)
+
+	return batchArchives, nil
+}
 
-	return batchFiles, nil
+// batchName names the i-th batch archive, counting from zero
+func batchName(i int) string {
+	return fmt.Sprintf("batch_%03d.txtar", i+1)
 }
@@ -1,9 +1,10 @@
 package core
 
 import (
-	"os"
-	"path/filepath"
+	"context"
+	"fmt"
 	"testing"
+	"testing/fstest"
 
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap/zaptest"
@@ -14,23 +15,6 @@ func TestCreateBatches(t *testing.T) {
 	// Create a test logger
 	logger := zapr.NewLogger(zaptest.NewLogger(t))
 
-	// Create temp directories
-	tempDir, err := os.MkdirTemp("", "nearwait_test")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Create project info
-	extractDir := filepath.Join(tempDir, "extract")
-	batchDir := filepath.Join(tempDir, "batches")
-	if err := os.MkdirAll(extractDir, 0o755); err != nil {
-		t.Fatalf("Failed to create extract dir: %v", err)
-	}
-	if err := os.MkdirAll(batchDir, 0o755); err != nil {
-		t.Fatalf("Failed to create batch dir: %v", err)
-	}
-
 	// Create test files
 	testFiles := []struct {
 		name    string
@@ -41,31 +25,9 @@ func TestCreateBatches(t *testing.T) {
 		{"file3.txt", "This is the largest file with even more content to ensure it exceeds certain batch sizes."},
 	}
 
+	staged := fstest.MapFS{}
 	for _, tf := range testFiles {
-		filePath := filepath.Join(extractDir, tf.name)
-		if err := os.WriteFile(filePath, []byte(tf.content), 0o644); err != nil {
-			t.Fatalf("Failed to write test file %s: %v", tf.name, err)
-		}
-	}
-
-	// Create a txtar file for easy inspection
-	var ar txtar.Archive
-	for _, tf := range testFiles {
-		ar.Files = append(ar.Files, txtar.File{
-			Name: tf.name,
-			Data: []byte(tf.content),
-		})
-	}
-	txtarPath := filepath.Join(tempDir, "test.txtar")
-	if err := os.WriteFile(txtarPath, txtar.Format(&ar), 0o644); err != nil {
-		t.Fatalf("Failed to write txtar file: %v", err)
-	}
-
-	projectInfo := ProjectInfo{
-		TempDir:    tempDir,
-		ExtractDir: extractDir,
-		BatchDir:   batchDir,
-		TxtarFile:  txtarPath,
+		staged[tf.name] = &fstest.MapFile{Data: []byte(tf.content), Mode: 0o644}
 	}
 
 	tests := []struct {
@@ -86,7 +48,7 @@ func TestCreateBatches(t *testing.T) {
 				batchKBytes: tt.batchKBytes,
 			}
 
-			batches, err := mp.createBatches(projectInfo)
+			batches, err := mp.createBatches(context.Background(), staged)
 			if err != nil {
 				t.Fatalf("createBatches() error = %v", err)
 			}
@@ -98,20 +60,7 @@ func TestCreateBatches(t *testing.T) {
 			// Verify batch contents if batching was enabled
 			if tt.batchKBytes > 0 && len(batches) > 0 {
 				var totalFiles int
-				for i, batchFile := range batches {
-					// Check that batch file exists
-					if _, err := os.Stat(batchFile); os.IsNotExist(err) {
-						t.Errorf("Batch file %s does not exist", batchFile)
-						continue
-					}
-
-					// Read and parse batch file
-					data, err := os.ReadFile(batchFile)
-					if err != nil {
-						t.Errorf("Failed to read batch file %s: %v", batchFile, err)
-						continue
-					}
-
+				for i, data := range batches {
 					archive := txtar.Parse(data)
 					t.Logf("Batch %d contains %d files", i+1, len(archive.Files))
 					totalFiles += len(archive.Files)
@@ -125,3 +74,38 @@ func TestCreateBatches(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateBatchesCountsComments(t *testing.T) {
+	// Files quoted for their txtar markers add the escape note to the comment
+	// of their batch, and the first batch also lists the paired tests
+	staged := fstest.MapFS{}
+	for i, size := range []int{300, 310, 320, 200, 180, 150, 120} {
+		data := []byte("-- marker --\n")
+		for len(data) < size {
+			data = append(data, "filler line\n"...)
+		}
+		staged[fmt.Sprintf("file%d.txtar", i)] = &fstest.MapFile{Data: data}
+	}
+
+	for _, paired := range [][]string{nil, {"core/a_test.go", "core/b_test.go"}} {
+		mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml")
+		mp.WithBatchKBytes(1)
+		mp.pairedTests = paired
+
+		batches, err := mp.createBatches(context.Background(), staged)
+		if err != nil {
+			t.Fatalf("createBatches() error = %v", err)
+		}
+		var total int
+		for i, data := range batches {
+			ar := txtar.Parse(data)
+			total += len(ar.Files)
+			if len(data) > 1024 && len(ar.Files) > 1 {
+				t.Errorf("batch %d with paired %v is %d bytes, want at most 1024", i+1, paired, len(data))
+			}
+		}
+		if total != len(staged) {
+			t.Errorf("batches hold %d files, want %d", total, len(staged))
+		}
+	}
+}
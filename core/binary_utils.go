@@ -0,0 +1,193 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// BinaryPolicy controls how a binary file is rendered into the txtar archive
+type BinaryPolicy string
+
+const (
+	// BinarySkip replaces the file contents with a one-line placeholder
+	BinarySkip BinaryPolicy = "skip"
+	// BinaryBase64 includes the file base64-encoded after a marker line
+	BinaryBase64 BinaryPolicy = "base64"
+	// BinaryMetadata includes only the size, sha256 and MIME type of the file
+	BinaryMetadata BinaryPolicy = "metadata"
+)
+
+// binaryNote marks binary files in the manifest
+const binaryNote = "binary"
+
+// binarySniffLen is how much of a file is inspected when detecting binary content
+const binarySniffLen = 8000
+
+// binaryMagic lists signatures of common binary formats
+var binaryMagic = [][]byte{
+	[]byte("\x89PNG\r\n\x1a\n"),
+	[]byte("\xff\xd8\xff"),        // JPEG
+	[]byte("GIF87a"),              // GIF
+	[]byte("GIF89a"),              // GIF
+	[]byte("%PDF-"),               // PDF
+	[]byte("PK\x03\x04"),          // zip, jar, docx
+	[]byte("\x1f\x8b"),            // gzip
+	[]byte("\xfd7zXZ\x00"),        // xz
+	[]byte("7z\xbc\xaf\x27\x1c"),  // 7z
+	[]byte("\x28\xb5\x2f\xfd"),    // zstd
+	[]byte("\x7fELF"),             // ELF
+	[]byte("\xcf\xfa\xed\xfe"),    // Mach-O 64-bit
+	[]byte("\xce\xfa\xed\xfe"),    // Mach-O 32-bit
+	[]byte("\xca\xfe\xba\xbe"),    // Mach-O universal, Java class
+	[]byte("SQLite format 3\x00"), // SQLite
+	[]byte("\x00asm"),             // WebAssembly
+	[]byte("wOFF"),                // WOFF font
+	[]byte("wOF2"),                // WOFF2 font
+}
+
+func ParseBinaryPolicy(s string) (BinaryPolicy, error) {
+	switch policy := BinaryPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case BinarySkip, BinaryBase64, BinaryMetadata:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown binary policy %q (want skip, base64 or metadata)", s)
+}
+
+// isBinary reports whether data looks like binary content, based on known
+// magic numbers, NUL bytes and the ratio of invalid UTF-8 sequences
+func isBinary(data []byte) bool {
+	for _, magic := range binaryMagic {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+
+	sample := data
+	if len(sample) > binarySniffLen {
+		sample = sample[:binarySniffLen]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+
+	total, invalid := 0, 0
+	for len(sample) > 0 {
+		r, size := utf8.DecodeRune(sample)
+		// A multi-byte rune cut off by the sample boundary is not evidence
+		if r == utf8.RuneError && size == 1 && len(sample) >= utf8.UTFMax {
+			invalid++
+		}
+		total++
+		sample = sample[size:]
+	}
+	return total > 0 && invalid*10 > total
+}
+
+// annotateBinaryFiles notes every binary file in the manifest so it is easy
+// to spot before enabling it. Files in known were sniffed by an earlier run
+// and are not read again.
+func (mg *ManifestGenerator) annotateBinaryFiles(manifest Manifest, known map[string]bool) Manifest {
+	if manifest.Notes == nil {
+		manifest.Notes = make(map[string]string)
+	}
+	for file := range manifest.FileList {
+		if _, ok := manifest.Notes[file]; ok {
+			continue
+		}
+		if _, ok := known[file]; ok {
+			continue
+		}
+		binary, err := mg.sniffBinary(file)
+		if err != nil {
+			mg.logger.V(1).Info("Skipping binary detection", "path", file, "error", err.Error())
+			continue
+		}
+		if binary {
+			manifest.Notes[file] = binaryNote
+		}
+	}
+	return manifest
+}
+
+func (mg *ManifestGenerator) sniffBinary(file string) (bool, error) {
+	f, err := mg.fsys.Open(filepath.ToSlash(file))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return isBinary(head[:n]), nil
+}
+
+// WithBinaryPolicy sets how binary files are rendered, by default and per file
+// extension (for example "png" or ".sqlite")
+func (mp *ManifestProcessor) WithBinaryPolicy(policy BinaryPolicy, byExt map[string]BinaryPolicy) *ManifestProcessor {
+	mp.binaryPolicy = policy
+	mp.binaryPolicies = make(map[string]BinaryPolicy, len(byExt))
+	for ext, p := range byExt {
+		mp.binaryPolicies[normalizeExt(ext)] = p
+	}
+	return mp
+}
+
+func (mp *ManifestProcessor) binaryPolicyFor(relPath string) BinaryPolicy {
+	if policy, ok := mp.binaryPolicies[normalizeExt(filepath.Ext(relPath))]; ok {
+		return policy
+	}
+	if mp.binaryPolicy == "" {
+		return BinarySkip
+	}
+	return mp.binaryPolicy
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// renderBinary replaces binary content with a placeholder, a base64 encoding
+// or a metadata summary according to policy
+func renderBinary(relPath string, content []byte, policy BinaryPolicy) []byte {
+	var buf bytes.Buffer
+	switch policy {
+	case BinaryBase64:
+		fmt.Fprintf(&buf, "[nearwait: base64-encoded binary file, %d bytes]\n", len(content))
+		encoded := base64.StdEncoding.EncodeToString(content)
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\n")
+			encoded = encoded[76:]
+		}
+		if encoded != "" {
+			buf.WriteString(encoded + "\n")
+		}
+	case BinaryMetadata:
+		fmt.Fprintf(&buf, "[nearwait: binary file omitted]\nsize: %d\nsha256: %x\nmime: %s\n",
+			len(content), sha256.Sum256(content), detectMIMEType(relPath, content))
+	default:
+		fmt.Fprintf(&buf, "[nearwait: binary file omitted, %d bytes]\n", len(content))
+	}
+	return buf.Bytes()
+}
+
+func detectMIMEType(relPath string, content []byte) string {
+	if mimeType := mime.TypeByExtension(filepath.Ext(relPath)); mimeType != "" {
+		return mimeType
+	}
+	return http.DetectContentType(content)
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"Plain text", []byte("package main\n\nfunc main() {}\n"), false},
+		{"UTF-8 text", []byte("héllo wörld ✓\n"), false},
+		{"Empty", nil, false},
+		{"NUL byte", []byte("abc\x00def"), true},
+		{"PNG magic", []byte("\x89PNG\r\n\x1a\nrest"), true},
+		{"SQLite magic", []byte("SQLite format 3\x00..."), true},
+		{"Invalid UTF-8", []byte(strings.Repeat("\xff\xfe\xfd", 20)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBinary(tt.data); got != tt.want {
+				t.Errorf("isBinary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderFileBinaryPolicies(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml")
+	mp.WithBinaryPolicy(BinarySkip, map[string]BinaryPolicy{
+		"png":     BinaryBase64,
+		".sqlite": BinaryMetadata,
+	})
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"Default skip", "bin/tool", "[nearwait: binary file omitted, 16 bytes]"},
+		{"Base64 by extension", "logo.PNG", "[nearwait: base64-encoded binary file, 16 bytes]\niVBORw0KGgoAAAANSUhEUg==\n"},
+		{"Metadata by extension", "data.sqlite", "sha256: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section, _ := mp.renderFile(tt.path, png)
+			got := string(section.Data)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("renderFile(%q) = %q, want it to contain %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	text, _ := mp.renderFile("main.go", []byte("package main\n"))
+	if string(text.Data) != "package main\n" {
+		t.Errorf("renderFile() changed text file: %q", text.Data)
+	}
+}
+
+func TestAnnotateBinaryFiles(t *testing.T) {
+	mg := NewManifestGenerator(testLogger(t))
+	mg.WithFS(fstest.MapFS{
+		"main.go":  {Data: []byte("package main\n")},
+		"logo.png": {Data: []byte("\x89PNG\r\n\x1a\n")},
+	})
+
+	manifest := mg.annotateBinaryFiles(Manifest{FileList: map[string]bool{
+		"main.go":  true,
+		"logo.png": true,
+	}}, nil)
+
+	if manifest.Notes["logo.png"] != binaryNote {
+		t.Errorf("Expected logo.png to be noted as binary, got %q", manifest.Notes["logo.png"])
+	}
+	if note, ok := manifest.Notes["main.go"]; ok {
+		t.Errorf("Expected no note for main.go, got %q", note)
+	}
+
+	// Entries already in the manifest are not read again
+	manifest = mg.annotateBinaryFiles(Manifest{FileList: map[string]bool{
+		"main.go":  true,
+		"logo.png": true,
+	}}, map[string]bool{"main.go": true, "logo.png": true})
+	if len(manifest.Notes) != 0 {
+		t.Errorf("Expected known entries to be skipped, got notes %v", manifest.Notes)
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"golang.org/x/tools/txtar"
+)
+
+// ErrEmptyManifest is returned by Build when the manifest enables no files
+var ErrEmptyManifest = errors.New("manifest file list is empty")
+
+// Bundle is what a run produces from the enabled files of a manifest
+type Bundle struct {
+	// Files holds the staged enabled files, as they appear in the bundle
+	Files fs.FS
+	// Archive is the txtar archive of every file, as written to the output sink
+	Archive []byte
+	// Batches holds the batch archives when a batch size is set
+	Batches [][]byte
+	// PairedTests lists the files added by test pairing
+	PairedTests []string
+	// Findings lists the secrets that were redacted
+	Findings []SecretFinding
+}
+
+// Build stages and renders the enabled files of the manifest and writes the
+// archive to the output sink. It neither touches the clipboard nor the
+// terminal, and stops early once ctx is done.
+func (mp *ManifestProcessor) Build(ctx context.Context) (*Bundle, error) {
+	mp.logger.V(1).Info("Processing manifest")
+	mp.report.reset()
+	defer mp.closeSources()
+	start := time.Now()
+	manifest, err := mp.reader.ReadManifest(mp.manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	mp.report.timeStage("read_manifest", start)
+
+	// Check if there are any uncommented entries in the manifest
+	hasUncommentedEntries := false
+	for _, isCommented := range manifest.FileList {
+		if !isCommented {
+			hasUncommentedEntries = true
+			break
+		}
+	}
+	if !hasUncommentedEntries {
+		return nil, ErrEmptyManifest
+	}
+	mp.manifest = manifest
+
+	if err := checkEntryPaths(manifest); err != nil {
+		return nil, err
+	}
+	if mp.sources, err = mp.fileSources(); err != nil {
+		return nil, err
+	}
+
+	mp.pairedTests = mp.pairTestFiles(manifest)
+	if len(mp.pairedTests) > 0 {
+		mp.logger.Info("Added paired test files", "files", mp.pairedTests)
+	}
+
+	// Generated files are resolved up front so a missing dependency or git
+	// ref fails before any work is done
+	start = time.Now()
+	mp.virtualFiles = append([]txtar.File(nil), mp.extraFiles...)
+	for _, generate := range []func(context.Context) ([]txtar.File, error){mp.diffFiles, mp.historyFiles, mp.depAPIFiles} {
+		files, err := generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mp.virtualFiles = append(mp.virtualFiles, files...)
+	}
+	mp.report.timeStage("generate", start)
+
+	mp.redactor = nil
+	if mp.redact {
+		allowlist, err := LoadAllowlist(allowlistPathFor(mp.manifestFile))
+		if err != nil {
+			return nil, fmt.Errorf("error reading allowlist: %w", err)
+		}
+		mp.redactor = NewRedactor(allowlist)
+	}
+
+	start = time.Now()
+	staged, err := mp.archiver.StageFiles(ctx, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error staging files: %w", err)
+	}
+	if err := mp.checkVirtualFiles(staged); err != nil {
+		return nil, err
+	}
+	projectInfo := mp.setupProjectInfo(staged)
+	mp.report.timeStage("stage", start)
+
+	start = time.Now()
+	archive, err := mp.archiver.ProcessTxtarArchive(ctx, manifest, projectInfo)
+	if err != nil {
+		return nil, err
+	}
+	mp.report.setArchive(mp.stagingNotes, mp.rendered, archive)
+	mp.report.addOutput(describeOutput(mp.output, projectInfo.TxtarFile))
+	mp.report.timeStage("render", start)
+
+	bundle := &Bundle{
+		Files:       staged,
+		Archive:     archive,
+		PairedTests: mp.pairedTests,
+		Findings:    mp.redactor.Findings(),
+	}
+	for _, finding := range bundle.Findings {
+		mp.logger.Info("Redacted secret",
+			"path", finding.Path,
+			"line", finding.Line,
+			"rule", finding.Rule,
+			"placeholder", finding.Placeholder)
+	}
+	if high := mp.redactor.HighConfidence(); len(high) > 0 && !mp.allowSecrets {
+		return nil, secretsError(high)
+	}
+	if tokens := EstimateTokens(archive); mp.maxTokens > 0 && tokens > mp.maxTokens {
+		return nil, overBudgetError(tokens, mp.maxTokens)
+	}
+
+	if mp.batchKBytes > 0 {
+		start = time.Now()
+		if bundle.Batches, err = mp.createBatches(ctx, staged); err != nil {
+			return nil, err
+		}
+		mp.report.timeStage("batch", start)
+	}
+	return bundle, nil
+}
+
+// checkVirtualFiles rejects generated files named like a staged file or
+// another generated file, which would make the archive ambiguous
+func (mp *ManifestProcessor) checkVirtualFiles(staged fs.FS) error {
+	seen := make(map[string]bool)
+	for _, f := range mp.virtualFiles {
+		if seen[f.Name] {
+			return fmt.Errorf("generated file %s is added twice", f.Name)
+		}
+		seen[f.Name] = true
+		if _, err := fs.Stat(staged, f.Name); err == nil {
+			return fmt.Errorf("generated file %s clashes with an enabled project file; disable the entry or the option generating it", f.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"io"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/tools/cover"
+)
+
+// CoveredLocations reads a go test -coverprofile and returns the covered
+// blocks of every file in the module with at least minStatements covered
+// statements, as paths relative to the module root
+func (m GoModule) CoveredLocations(r io.Reader, minStatements int) ([]SourceLocation, error) {
+	profiles, err := cover.ParseProfilesFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var locs []SourceLocation
+	for _, profile := range profiles {
+		dir, ok := m.packageDir(path.Dir(profile.FileName))
+		if !ok {
+			continue
+		}
+		file := filepath.Join(dir, path.Base(profile.FileName))
+
+		var blocks []SourceLocation
+		covered := 0
+		for _, block := range profile.Blocks {
+			if block.Count == 0 {
+				continue
+			}
+			covered += block.NumStmt
+			blocks = append(blocks, SourceLocation{Path: file, Lines: LineRange{Start: block.StartLine, End: block.EndLine}})
+		}
+		if len(blocks) == 0 || covered < minStatements {
+			continue
+		}
+		locs = append(locs, blocks...)
+	}
+	return locs, nil
+}
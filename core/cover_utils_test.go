@@ -0,0 +1,60 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleCoverProfile = `mode: set
+example.com/app/core/batch.go:4.2,4.13 1 1
+example.com/app/core/batch.go:5.3,6.1 1 0
+example.com/app/core/batch.go:7.2,9.14 3 1
+example.com/app/core/render.go:10.16,12.2 1 1
+example.com/app/core/unused.go:3.20,5.2 2 0
+example.com/app/main.go:5.13,7.2 1 1
+github.com/spf13/cobra/command.go:10.1,12.2 4 1
+`
+
+func TestCoveredLocations(t *testing.T) {
+	module := GoModule{Path: "example.com/app", Dir: "."}
+	batch := filepath.Join("core", "batch.go")
+
+	tests := []struct {
+		name          string
+		minStatements int
+		want          []SourceLocation
+	}{
+		{
+			name:          "every covered file",
+			minStatements: 1,
+			want: []SourceLocation{
+				{Path: batch, Lines: LineRange{Start: 4, End: 4}},
+				{Path: batch, Lines: LineRange{Start: 7, End: 9}},
+				{Path: filepath.Join("core", "render.go"), Lines: LineRange{Start: 10, End: 12}},
+				{Path: "main.go", Lines: LineRange{Start: 5, End: 7}},
+			},
+		},
+		{
+			name:          "minimum statements",
+			minStatements: 4,
+			want: []SourceLocation{
+				{Path: batch, Lines: LineRange{Start: 4, End: 4}},
+				{Path: batch, Lines: LineRange{Start: 7, End: 9}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := module.CoveredLocations(strings.NewReader(sampleCoverProfile), tt.minStatements)
+			if err != nil {
+				t.Fatalf("CoveredLocations() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CoveredLocations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
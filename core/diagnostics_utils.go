@@ -0,0 +1,50 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// DiagnosticsFile names the virtual file holding compiler and linter output
+const DiagnosticsFile = "diagnostics.txt"
+
+// diagnosticPatterns match the position at the start of a diagnostic:
+// "path:line:col: msg" and "path:line: msg" from go build, go vet (which
+// may prefix "vet: "), golangci-lint, gcc and most compilers, rustc's "--> path:line:col" and
+// tsc's "path(line,col): msg"
+var diagnosticPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*(?:[\w-]+:\s+)?((?:[A-Za-z]:)?[^\s:()]+):(\d+)(?::\d+)?:(?:\s|$)`),
+	regexp.MustCompile(`^\s*-->\s*((?:[A-Za-z]:)?[^\s:()]+):(\d+)(?::\d+)?\s*$`),
+	regexp.MustCompile(`^\s*([^\s:()]+)\((\d+),\d+\):\s`),
+}
+
+// ParseDiagnostics extracts the source positions of compiler, vet and linter
+// diagnostics, in order of appearance
+func ParseDiagnostics(r io.Reader) ([]SourceLocation, error) {
+	var locs []SourceLocation
+	seen := make(map[SourceLocation]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		for _, pattern := range diagnosticPatterns {
+			m := pattern.FindStringSubmatch(scanner.Text())
+			if m == nil {
+				continue
+			}
+			line, err := strconv.Atoi(m[2])
+			if err != nil || line < 1 {
+				break
+			}
+			loc := SourceLocation{Path: m[1], Lines: LineRange{Start: line, End: line}}
+			if !seen[loc] {
+				seen[loc] = true
+				locs = append(locs, loc)
+			}
+			break
+		}
+	}
+	return locs, scanner.Err()
+}
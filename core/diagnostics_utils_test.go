@@ -0,0 +1,63 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []SourceLocation
+	}{
+		{
+			name:   "go build",
+			output: "# example.com/app/core\n./core/batch.go:12:5: undefined: foo\n./core/batch.go:12:9: too many errors\n",
+			want: []SourceLocation{
+				{Path: "./core/batch.go", Lines: LineRange{Start: 12, End: 12}},
+			},
+		},
+		{
+			name:   "go vet",
+			output: "# [example.com/app/core]\nvet: core/render.go:40:2: unreachable code\ncore/render.go:7: possible misuse\n",
+			want: []SourceLocation{
+				{Path: "core/render.go", Lines: LineRange{Start: 40, End: 40}},
+				{Path: "core/render.go", Lines: LineRange{Start: 7, End: 7}},
+			},
+		},
+		{
+			name:   "golangci-lint",
+			output: "cmd/root.go:88:2: ineffectual assignment to err (ineffassign)\n\terr = nil\n\t^\n",
+			want: []SourceLocation{
+				{Path: "cmd/root.go", Lines: LineRange{Start: 88, End: 88}},
+			},
+		},
+		{
+			name:   "other compilers",
+			output: "src/main.c:3:10: error: expected ';'\nerror[E0308]: mismatched types\n --> src/lib.rs:14:5\nsrc/app.ts(7,3): error TS2322: Type 'string' is not assignable\n",
+			want: []SourceLocation{
+				{Path: "src/main.c", Lines: LineRange{Start: 3, End: 3}},
+				{Path: "src/lib.rs", Lines: LineRange{Start: 14, End: 14}},
+				{Path: "src/app.ts", Lines: LineRange{Start: 7, End: 7}},
+			},
+		},
+		{
+			name:   "no positions",
+			output: "ok  \texample.com/app/core\t0.02s\nFAIL\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDiagnostics(strings.NewReader(tt.output))
+			if err != nil {
+				t.Fatalf("ParseDiagnostics() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDiagnostics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
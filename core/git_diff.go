@@ -0,0 +1,212 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"golang.org/x/tools/txtar"
+)
+
+// ChangesDiffFile names the virtual file holding the diff of the enabled files
+const ChangesDiffFile = "changes.diff"
+
+// DefaultDiffContext is the number of context lines in changes.diff unless
+// set otherwise
+const DefaultDiffContext = 3
+
+// WithDiff appends a unified diff of the enabled files against ref, such as
+// HEAD or main, with contextLines lines of context. Files the ref does not
+// have are only included when they are tracked or untracked is set. An empty
+// ref disables the diff.
+func (mp *ManifestProcessor) WithDiff(ref string, contextLines int, untracked bool) *ManifestProcessor {
+	mp.diffRef = ref
+	mp.diffContext = contextLines
+	mp.diffUntracked = untracked
+	return mp
+}
+
+// diffFiles renders changes.diff for the enabled regular files of the manifest
+func (mp *ManifestProcessor) diffFiles(ctx context.Context) ([]txtar.File, error) {
+	if mp.diffRef == "" {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for file, isCommented := range mp.manifest.FileList {
+		if isCommented {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(mp.dir, file)); err == nil && info.Mode().IsRegular() {
+			files = append(files, file)
+		}
+	}
+
+	repo, err := OpenGitRepo(mp.dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := repo.Diff(mp.diffRef, files, mp.diffContext, mp.diffUntracked)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		mp.logger.Info("Enabled files do not differ from " + mp.diffRef)
+		return nil, nil
+	}
+	return []txtar.File{{Name: ChangesDiffFile, Data: data}}, nil
+}
+
+// Diff renders a unified diff from the files as of rev to their contents in
+// the working tree. files are relative to the project directory.
+func (g *GitRepo) Diff(rev string, files []string, contextLines int, untracked bool) ([]byte, error) {
+	commit, err := g.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	index, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool, len(index.Entries))
+	for _, entry := range index.Entries {
+		tracked[entry.Name] = true
+	}
+
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+
+	var patch filesPatch
+	for _, file := range sorted {
+		repoPath, ok := g.repoPath(file)
+		if !ok {
+			continue
+		}
+		newContent, err := os.ReadFile(filepath.Join(g.dir, file))
+		if err != nil {
+			return nil, err
+		}
+		to := &patchFile{
+			path: filepath.ToSlash(file),
+			hash: plumbing.ComputeHash(plumbing.BlobObject, newContent),
+			mode: filemode.Regular,
+		}
+
+		var from *patchFile
+		var oldContent []byte
+		switch entry, err := tree.File(repoPath); {
+		case errors.Is(err, object.ErrFileNotFound):
+			if !untracked && !tracked[repoPath] {
+				continue
+			}
+		case err != nil:
+			return nil, fmt.Errorf("error reading %s at %s: %w", file, rev, err)
+		default:
+			if entry.Hash == to.hash {
+				continue
+			}
+			contents, err := entry.Contents()
+			if err != nil {
+				return nil, err
+			}
+			oldContent = []byte(contents)
+			from = &patchFile{path: to.path, hash: entry.Hash, mode: entry.Mode}
+		}
+
+		fp := &filePatch{from: from, to: to, binary: isBinary(oldContent) || isBinary(newContent)}
+		if !fp.binary {
+			for _, d := range diff.Do(string(oldContent), string(newContent)) {
+				fp.chunks = append(fp.chunks, patchChunk{content: d.Text, op: chunkOperation(d.Type)})
+			}
+		}
+		patch = append(patch, fp)
+	}
+
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := fdiff.NewUnifiedEncoder(&buf, contextLines).Encode(patch); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// repoPath maps a project path to its slash-separated path in the repository
+func (g *GitRepo) repoPath(file string) (string, bool) {
+	rel, err := filepath.Rel(g.root, filepath.Join(g.dir, file))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func chunkOperation(op diffmatchpatch.Operation) fdiff.Operation {
+	switch op {
+	case diffmatchpatch.DiffInsert:
+		return fdiff.Add
+	case diffmatchpatch.DiffDelete:
+		return fdiff.Delete
+	}
+	return fdiff.Equal
+}
+
+// filesPatch, filePatch, patchFile and patchChunk adapt working tree
+// contents to the interfaces of go-git's unified diff encoder
+type filesPatch []fdiff.FilePatch
+
+func (p filesPatch) FilePatches() []fdiff.FilePatch { return p }
+func (p filesPatch) Message() string                { return "" }
+
+type filePatch struct {
+	from, to *patchFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (p *filePatch) IsBinary() bool        { return p.binary }
+func (p *filePatch) Chunks() []fdiff.Chunk { return p.chunks }
+
+func (p *filePatch) Files() (fdiff.File, fdiff.File) {
+	// A nil *patchFile inside the interface would not compare equal to nil
+	if p.from == nil {
+		return nil, p.to
+	}
+	return p.from, p.to
+}
+
+type patchFile struct {
+	path string
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.mode }
+func (f *patchFile) Path() string            { return f.path }
+
+type patchChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c patchChunk) Content() string       { return c.content }
+func (c patchChunk) Type() fdiff.Operation { return c.op }
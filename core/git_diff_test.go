@@ -0,0 +1,72 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitRepoDiff(t *testing.T) {
+	dir := gitTestRepo(t)
+	repo, err := OpenGitRepo(dir)
+	if err != nil {
+		t.Fatalf("OpenGitRepo() error = %v", err)
+	}
+	files := []string{
+		"main.go",
+		filepath.Join("core", "batch.go"),
+		filepath.Join("core", "new.go"),
+		filepath.Join("core", "render.go"),
+	}
+
+	tests := []struct {
+		name      string
+		rev       string
+		untracked bool
+		want      []string
+		unwanted  []string
+	}{
+		{
+			name: "working tree against HEAD",
+			rev:  "HEAD",
+			want: []string{
+				"--- a/core/render.go\n+++ b/core/render.go\n@@ -1 +1,3 @@\n package core\n+\n+// staged\n",
+				"--- a/main.go\n+++ b/main.go\n",
+			},
+			unwanted: []string{"core/batch.go", "core/new.go"},
+		},
+		{
+			name:      "branch against main with untracked files",
+			rev:       "main",
+			untracked: true,
+			want: []string{
+				"+// committed\n",
+				"diff --git a/core/new.go b/core/new.go\nnew file mode 100644\n",
+				"--- /dev/null\n+++ b/core/new.go\n@@ -0,0 +1 @@\n+package core\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.Diff(tt.rev, files, 3, tt.untracked)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Diff() missing %q:\n%s", want, got)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(string(got), unwanted) {
+					t.Errorf("Diff() unexpectedly contains %q:\n%s", unwanted, got)
+				}
+			}
+		})
+	}
+
+	if _, err := repo.Diff("no-such-branch", files, 3, false); err == nil {
+		t.Error("Diff() of an unknown ref expected an error")
+	}
+}
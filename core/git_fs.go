@@ -0,0 +1,154 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// RevisionFS serves the project directory as of rev, read from the git
+// object store rather than the working tree
+func (g *GitRepo) RevisionFS(rev string) (fs.FS, error) {
+	commit, err := g.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	fsys := fs.FS(&treeFS{tree: tree, modTime: commit.Committer.When})
+
+	dir, err := filepath.Rel(g.root, g.dir)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "." {
+		return fsys, nil
+	}
+	return fs.Sub(fsys, filepath.ToSlash(dir))
+}
+
+// treeFS implements fs.FS over a git tree object
+type treeFS struct {
+	tree    *object.Tree
+	modTime time.Time
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &treeDir{fsys: t, tree: t.tree, info: t.dirInfo(".")}, nil
+	}
+
+	entry, err := t.tree.FindEntry(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if entry.Mode == filemode.Dir {
+		sub, err := t.tree.Tree(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &treeDir{fsys: t, tree: sub, info: t.dirInfo(name)}, nil
+	}
+
+	file, err := t.tree.TreeEntryFile(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &treeFile{ReadCloser: reader, info: t.fileInfo(path.Base(name), file)}, nil
+}
+
+func (t *treeFS) dirInfo(name string) treeFileInfo {
+	return treeFileInfo{name: path.Base(name), mode: fs.ModeDir | 0o755, modTime: t.modTime}
+}
+
+func (t *treeFS) fileInfo(name string, file *object.File) treeFileInfo {
+	mode := fs.FileMode(0o644)
+	switch file.Mode {
+	case filemode.Executable:
+		mode = 0o755
+	case filemode.Symlink:
+		mode = fs.ModeSymlink | 0o777
+	}
+	return treeFileInfo{name: name, size: file.Size, mode: mode, modTime: t.modTime}
+}
+
+type treeFile struct {
+	io.ReadCloser
+	info treeFileInfo
+}
+
+func (f *treeFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// treeDir is an open directory of a treeFS
+type treeDir struct {
+	fsys   *treeFS
+	tree   *object.Tree
+	info   treeFileInfo
+	offset int
+}
+
+func (d *treeDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *treeDir) Close() error               { return nil }
+
+func (d *treeDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *treeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.tree.Entries[d.offset:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(remaining) {
+		remaining = remaining[:n]
+	}
+
+	entries := make([]fs.DirEntry, 0, len(remaining))
+	for i := range remaining {
+		entry := &remaining[i]
+		if entry.Mode == filemode.Dir {
+			entries = append(entries, fs.FileInfoToDirEntry(d.fsys.dirInfo(entry.Name)))
+			continue
+		}
+		if entry.Mode == filemode.Submodule {
+			continue
+		}
+		file, err := d.tree.TreeEntryFile(entry)
+		if err != nil {
+			return entries, fmt.Errorf("error reading %s: %w", entry.Name, err)
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(d.fsys.fileInfo(entry.Name, file)))
+	}
+	d.offset += len(remaining)
+	return entries, nil
+}
+
+type treeFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i treeFileInfo) Name() string       { return i.name }
+func (i treeFileInfo) Size() int64        { return i.size }
+func (i treeFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i treeFileInfo) ModTime() time.Time { return i.modTime }
+func (i treeFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i treeFileInfo) Sys() any           { return nil }
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGitRepoRevisionFS(t *testing.T) {
+	dir := gitTestRepo(t)
+
+	tests := []struct {
+		name     string
+		dir      string
+		rev      string
+		file     string
+		want     string
+		wantDir  string
+		wantList []string
+	}{
+		{
+			name:     "main",
+			dir:      dir,
+			rev:      "main",
+			file:     "core/batch.go",
+			want:     "package core\n",
+			wantDir:  ".",
+			wantList: []string{"core", "docs", "main.go"},
+		},
+		{
+			name:     "head ignores staged and unstaged changes",
+			dir:      dir,
+			rev:      "HEAD",
+			file:     "core/render.go",
+			want:     "package core\n",
+			wantDir:  "core",
+			wantList: []string{"batch.go", "render.go"},
+		},
+		{
+			name:     "committed change",
+			dir:      dir,
+			rev:      "feature",
+			file:     "core/batch.go",
+			want:     "package core\n\n// committed\n",
+			wantDir:  "docs",
+			wantList: []string{"notes.md"},
+		},
+		{
+			name:     "project in a subdirectory",
+			dir:      filepath.Join(dir, "core"),
+			rev:      "HEAD",
+			file:     "batch.go",
+			want:     "package core\n\n// committed\n",
+			wantDir:  ".",
+			wantList: []string{"batch.go", "render.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := OpenGitRepo(tt.dir)
+			if err != nil {
+				t.Fatalf("OpenGitRepo() error = %v", err)
+			}
+			fsys, err := repo.RevisionFS(tt.rev)
+			if err != nil {
+				t.Fatalf("RevisionFS() error = %v", err)
+			}
+
+			data, err := fs.ReadFile(fsys, tt.file)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("ReadFile() = %q, want %q", data, tt.want)
+			}
+
+			entries, err := fs.ReadDir(fsys, tt.wantDir)
+			if err != nil {
+				t.Fatalf("ReadDir() error = %v", err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			if !reflect.DeepEqual(got, tt.wantList) {
+				t.Errorf("ReadDir() = %v, want %v", got, tt.wantList)
+			}
+		})
+	}
+}
+
+func TestGitRepoRevisionFSMissingFile(t *testing.T) {
+	repo, err := OpenGitRepo(gitTestRepo(t))
+	if err != nil {
+		t.Fatalf("OpenGitRepo() error = %v", err)
+	}
+	fsys, err := repo.RevisionFS("main")
+	if err != nil {
+		t.Fatalf("RevisionFS() error = %v", err)
+	}
+	if _, err := fs.Stat(fsys, "core/new.go"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestRevisionDir(t *testing.T) {
+	tests := map[string]string{
+		".":            "worktree",
+		"main":         "main",
+		"origin/main":  "origin-main",
+		"HEAD~2":       "HEAD-2",
+		"v1.0^":        "v1.0-",
+		"feature:core": "feature-core",
+	}
+	for rev, want := range tests {
+		if got := revisionDir(rev); got != want {
+			t.Errorf("revisionDir(%q) = %q, want %q", rev, got, want)
+		}
+	}
+}
+
+func TestRevisionsWithDiff(t *testing.T) {
+	dir := gitTestRepo(t)
+	manifestFile := filepath.Join(dir, ".nearwait.yml")
+	if err := os.WriteFile(manifestFile, []byte("filelist:\n- main.go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		revs    []string
+		diff    string
+		history int
+		wantErr bool
+	}{
+		{name: "revision", revs: []string{"main"}},
+		{name: "working copy with diff", revs: []string{WorktreeRev}, diff: "HEAD"},
+		{name: "revision with diff", revs: []string{"main"}, diff: "HEAD", wantErr: true},
+		{name: "revision with history", revs: []string{WorktreeRev, "main"}, history: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewManifestProcessor(testLogger(t), false, manifestFile).
+				WithDir(dir).
+				WithOutput(NewMemorySink()).
+				WithRevisions(tt.revs, len(tt.revs) > 1).
+				WithDiff(tt.diff, 3, false).
+				WithHistory(tt.history, false, 0)
+			if _, err := processor.Build(t.Context()); (err != nil) != tt.wantErr {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"golang.org/x/tools/txtar"
+)
+
+// HistoryFile names the virtual file holding the commit history of the
+// enabled files
+const HistoryFile = "history.txt"
+
+// DefaultHistoryKBytes caps the size of history.txt unless set otherwise
+const DefaultHistoryKBytes = 16
+
+// HistoryCommit is one commit in the history of a file
+type HistoryCommit struct {
+	Hash    string
+	Date    time.Time
+	Subject string
+	Body    string
+}
+
+// FileHistory holds the most recent commits touching a file
+type FileHistory struct {
+	Path    string
+	Commits []HistoryCommit
+}
+
+// WithHistory appends the last limit commits touching each enabled file,
+// with their message bodies when body is set, capped at maxBytes in total
+// (0 = no cap). A limit of 0 disables the history.
+func (mp *ManifestProcessor) WithHistory(limit int, body bool, maxBytes int) *ManifestProcessor {
+	mp.historyLimit = limit
+	mp.historyBody = body
+	mp.historyMaxBytes = maxBytes
+	return mp
+}
+
+// historyFiles renders history.txt for the enabled regular files of the manifest
+func (mp *ManifestProcessor) historyFiles(ctx context.Context) ([]txtar.File, error) {
+	if mp.historyLimit <= 0 {
+		return nil, nil
+	}
+
+	var files []string
+	for file, isCommented := range mp.manifest.FileList {
+		if isCommented {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(mp.dir, file)); err == nil && info.Mode().IsRegular() {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	repo, err := OpenGitRepo(mp.dir)
+	if err != nil {
+		return nil, err
+	}
+	var histories []FileHistory
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		commits, err := repo.FileHistory(file, mp.historyLimit)
+		if err != nil {
+			return nil, fmt.Errorf("error reading history of %s: %w", file, err)
+		}
+		if len(commits) > 0 {
+			histories = append(histories, FileHistory{Path: file, Commits: commits})
+		}
+	}
+	if len(histories) == 0 {
+		mp.logger.Info("Enabled files have no commit history")
+		return nil, nil
+	}
+	return []txtar.File{{Name: HistoryFile, Data: renderHistory(histories, mp.historyBody, mp.historyMaxBytes)}}, nil
+}
+
+// FileHistory lists the last limit commits reachable from HEAD that touched
+// file, newest first. file is relative to the project directory; untracked
+// files have no history.
+func (g *GitRepo) FileHistory(file string, limit int) ([]HistoryCommit, error) {
+	repoPath, ok := g.repoPath(file)
+	if !ok {
+		return nil, fmt.Errorf("%s is outside the repository", file)
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("error resolving HEAD: %w", err)
+	}
+	iter, err := g.repo.Log(&git.LogOptions{
+		From:       head.Hash(),
+		Order:      git.LogOrderCommitterTime,
+		PathFilter: func(path string) bool { return path == repoPath },
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []HistoryCommit
+	err = iter.ForEach(func(c *object.Commit) error {
+		subject, body, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		commits = append(commits, HistoryCommit{
+			Hash:    c.Hash.String()[:7],
+			Date:    c.Author.When,
+			Subject: strings.TrimSpace(subject),
+			Body:    strings.TrimSpace(body),
+		})
+		if len(commits) == limit {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// renderHistory lists the commits of each file under its path. Once maxBytes
+// would be exceeded the remaining commits are counted instead of listed.
+func renderHistory(histories []FileHistory, body bool, maxBytes int) []byte {
+	var buf bytes.Buffer
+	omitted := 0
+	for _, history := range histories {
+		header := history.Path + "\n"
+		if buf.Len() > 0 {
+			header = "\n" + header
+		}
+		for i, commit := range history.Commits {
+			var entry strings.Builder
+			if i == 0 {
+				entry.WriteString(header)
+			}
+			fmt.Fprintf(&entry, "  %s %s %s\n", commit.Hash, commit.Date.Format(time.DateOnly), commit.Subject)
+			if body && commit.Body != "" {
+				for _, line := range strings.Split(commit.Body, "\n") {
+					entry.WriteString(strings.TrimRight("      "+line, " ") + "\n")
+				}
+			}
+			if omitted > 0 || (maxBytes > 0 && buf.Len()+entry.Len() > maxBytes) {
+				omitted += len(history.Commits) - i
+				break
+			}
+			buf.WriteString(entry.String())
+		}
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&buf, "\n... %d more commits omitted to stay within the size limit\n", omitted)
+	}
+	return buf.Bytes()
+}
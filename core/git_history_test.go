@@ -0,0 +1,99 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGitRepoFileHistory(t *testing.T) {
+	repo, err := OpenGitRepo(gitTestRepo(t))
+	if err != nil {
+		t.Fatalf("OpenGitRepo() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		file  string
+		limit int
+		want  []string
+	}{
+		{name: "all commits", file: filepath.Join("core", "batch.go"), limit: 5, want: []string{"change batch", "initial"}},
+		{name: "limited", file: filepath.Join("core", "batch.go"), limit: 1, want: []string{"change batch"}},
+		{name: "untouched since initial", file: "main.go", limit: 5, want: []string{"initial"}},
+		{name: "untracked", file: filepath.Join("core", "new.go"), limit: 5, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, err := repo.FileHistory(tt.file, tt.limit)
+			if err != nil {
+				t.Fatalf("FileHistory() error = %v", err)
+			}
+			var got []string
+			for _, commit := range commits {
+				got = append(got, commit.Subject)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FileHistory() subjects = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderHistory(t *testing.T) {
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	histories := []FileHistory{
+		{Path: "core/batch.go", Commits: []HistoryCommit{
+			{Hash: "a1b2c3d", Date: date, Subject: "Split batches", Body: "Large files went over the limit.\n\nFixes #12"},
+			{Hash: "e4f5a6b", Date: date, Subject: "Add batching"},
+		}},
+		{Path: "main.go", Commits: []HistoryCommit{
+			{Hash: "c7d8e9f", Date: date, Subject: "Initial commit"},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		body     bool
+		maxBytes int
+		want     string
+	}{
+		{
+			name: "subjects",
+			want: "core/batch.go\n" +
+				"  a1b2c3d 2024-03-01 Split batches\n" +
+				"  e4f5a6b 2024-03-01 Add batching\n" +
+				"\nmain.go\n" +
+				"  c7d8e9f 2024-03-01 Initial commit\n",
+		},
+		{
+			name: "bodies",
+			body: true,
+			want: "core/batch.go\n" +
+				"  a1b2c3d 2024-03-01 Split batches\n" +
+				"      Large files went over the limit.\n" +
+				"\n" +
+				"      Fixes #12\n" +
+				"  e4f5a6b 2024-03-01 Add batching\n" +
+				"\nmain.go\n" +
+				"  c7d8e9f 2024-03-01 Initial commit\n",
+		},
+		{
+			name:     "size cap",
+			maxBytes: 60,
+			want: "core/batch.go\n" +
+				"  a1b2c3d 2024-03-01 Split batches\n" +
+				"\n... 2 more commits omitted to stay within the size limit\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(renderHistory(histories, tt.body, tt.maxBytes)); got != tt.want {
+				t.Errorf("renderHistory() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GitRepo reads changes from the git repository containing a project,
+// without needing a git binary
+type GitRepo struct {
+	repo *git.Repository
+	// root is the top of the worktree and dir the project directory in it
+	root string
+	dir  string
+}
+
+// OpenGitRepo opens the repository containing dir, searching parent
+// directories for .git
+func OpenGitRepo(dir string) (*GitRepo, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := git.PlainOpenWithOptions(abs, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("error opening git repository: %w", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(worktree.Filesystem.Root())
+	if err != nil {
+		return nil, err
+	}
+	if abs, err = filepath.EvalSymlinks(abs); err != nil {
+		return nil, err
+	}
+	return &GitRepo{repo: repo, root: root, dir: abs}, nil
+}
+
+// resolveCommit resolves a branch, tag or commit hash
+func (g *GitRepo) resolveCommit(rev string) (*object.Commit, error) {
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", rev, err)
+	}
+	return g.repo.CommitObject(*hash)
+}
+
+// BranchChanges lists the files changed since the merge base of HEAD and
+// ref, including uncommitted and untracked changes
+func (g *GitRepo) BranchChanges(ref string) ([]string, error) {
+	head, err := g.resolveCommit("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	other, err := g.resolveCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := head.MergeBase(other)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("HEAD and %s have no common ancestor", ref)
+	}
+
+	changed, err := g.treeChanges(bases[0], head)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := g.statusChanges(func(s *git.FileStatus) bool {
+		return s.Staging != git.Unmodified || s.Worktree != git.Unmodified
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g.projectFiles(append(changed, pending...)), nil
+}
+
+// StagedChanges lists the files whose index entry differs from HEAD
+func (g *GitRepo) StagedChanges() ([]string, error) {
+	files, err := g.statusChanges(func(s *git.FileStatus) bool {
+		return s.Staging != git.Unmodified && s.Staging != git.Untracked
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g.projectFiles(files), nil
+}
+
+// UnstagedChanges lists the files whose working copy differs from the index,
+// including untracked files
+func (g *GitRepo) UnstagedChanges() ([]string, error) {
+	files, err := g.statusChanges(func(s *git.FileStatus) bool {
+		return s.Worktree != git.Unmodified
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g.projectFiles(files), nil
+}
+
+// CommitChanges lists the files a commit changed relative to its first parent
+func (g *GitRepo) CommitChanges(rev string) ([]string, error) {
+	commit, err := g.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+	var parent *object.Commit
+	if commit.NumParents() > 0 {
+		if parent, err = commit.Parent(0); err != nil {
+			return nil, err
+		}
+	}
+	files, err := g.treeChanges(parent, commit)
+	if err != nil {
+		return nil, err
+	}
+	return g.projectFiles(files), nil
+}
+
+// treeChanges lists the paths that differ between two commits; a nil from
+// commit stands for the empty tree
+func (g *GitRepo) treeChanges(from, to *object.Commit) ([]string, error) {
+	var fromTree *object.Tree
+	if from != nil {
+		tree, err := from.Tree()
+		if err != nil {
+			return nil, err
+		}
+		fromTree = tree
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, change := range changes {
+		if change.To.Name != "" {
+			files = append(files, change.To.Name)
+		}
+	}
+	return files, nil
+}
+
+func (g *GitRepo) statusChanges(include func(*git.FileStatus) bool) ([]string, error) {
+	worktree, err := g.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for file, s := range status {
+		if include(s) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// projectFiles maps repository paths to sorted, deduplicated paths relative
+// to the project directory, dropping files outside it and deleted files
+func (g *GitRepo) projectFiles(repoPaths []string) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, repoPath := range repoPaths {
+		rel, err := filepath.Rel(g.dir, filepath.Join(g.root, filepath.FromSlash(repoPath)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || seen[rel] {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(g.dir, rel)); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		seen[rel] = true
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitTestRepo creates a repository with a main branch and a feature branch
+// holding one commit, staged, unstaged and untracked changes
+func gitTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := writeTestModule(t, map[string]string{
+		"core/batch.go":  "package core\n",
+		"core/render.go": "package core\n",
+		"docs/notes.md":  "notes\n",
+		"main.go":        "package main\n",
+	})
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(message string) {
+		t.Helper()
+		if err := worktree.AddGlob("."); err != nil {
+			t.Fatalf("AddGlob() error = %v", err)
+		}
+		signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+		if _, err := worktree.Commit(message, &git.CommitOptions{Author: signature}); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commit("initial")
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/main", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := worktree.Checkout(&git.CheckoutOptions{Branch: "refs/heads/feature", Create: true}); err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	write("core/batch.go", "package core\n\n// committed\n")
+	commit("change batch")
+
+	write("core/render.go", "package core\n\n// staged\n")
+	if _, err := worktree.Add("core/render.go"); err != nil {
+		t.Fatal(err)
+	}
+	write("main.go", "package main\n\n// unstaged\n")
+	write("core/new.go", "package core\n")
+	if err := os.Remove(filepath.Join(dir, "docs", "notes.md")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestGitRepoChanges(t *testing.T) {
+	dir := gitTestRepo(t)
+	batch := filepath.Join("core", "batch.go")
+	render := filepath.Join("core", "render.go")
+	newFile := filepath.Join("core", "new.go")
+
+	tests := []struct {
+		name  string
+		dir   string
+		files func(*GitRepo) ([]string, error)
+		want  []string
+	}{
+		{
+			name:  "changed since main",
+			dir:   dir,
+			files: func(g *GitRepo) ([]string, error) { return g.BranchChanges("main") },
+			want:  []string{batch, newFile, render, "main.go"},
+		},
+		{
+			name:  "staged",
+			dir:   dir,
+			files: (*GitRepo).StagedChanges,
+			want:  []string{render},
+		},
+		{
+			name:  "unstaged",
+			dir:   dir,
+			files: (*GitRepo).UnstagedChanges,
+			want:  []string{newFile, "main.go"},
+		},
+		{
+			name:  "commit",
+			dir:   dir,
+			files: func(g *GitRepo) ([]string, error) { return g.CommitChanges("HEAD") },
+			want:  []string{batch},
+		},
+		{
+			name:  "root commit",
+			dir:   dir,
+			files: func(g *GitRepo) ([]string, error) { return g.CommitChanges("main") },
+			want:  []string{batch, render, "main.go"},
+		},
+		{
+			name:  "project in a subdirectory",
+			dir:   filepath.Join(dir, "core"),
+			files: func(g *GitRepo) ([]string, error) { return g.BranchChanges("main") },
+			want:  []string{"batch.go", "new.go", "render.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := OpenGitRepo(tt.dir)
+			if err != nil {
+				t.Fatalf("OpenGitRepo() error = %v", err)
+			}
+			got, err := tt.files(repo)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
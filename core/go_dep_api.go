@@ -0,0 +1,278 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/txtar"
+)
+
+// depAPIDir is the directory virtual dependency outlines are placed under
+const depAPIDir = "deps"
+
+// DepModule is a dependency resolved to a directory in the local module cache
+type DepModule struct {
+	Path    string
+	Version string
+	// Dir is the directory of the requested package inside the module
+	Dir string
+}
+
+// WithDepAPI appends an outline of the exported API of each given module or
+// package, read from the local module cache, to the bundle
+func (mp *ManifestProcessor) WithDepAPI(packages []string) *ManifestProcessor {
+	mp.depAPI = packages
+	return mp
+}
+
+// ResolveDep finds the version of the module providing pkg in go.mod, falling
+// back to go.sum, and locates it in the module cache without network access
+func (m GoModule) ResolveDep(pkg string) (DepModule, error) {
+	goModPath := filepath.Join(m.Dir, "go.mod")
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return DepModule{}, err
+	}
+	file, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return DepModule{}, err
+	}
+
+	var dep DepModule
+	for _, req := range file.Require {
+		if providesPackage(req.Mod.Path, pkg) && len(req.Mod.Path) > len(dep.Path) {
+			dep = DepModule{Path: req.Mod.Path, Version: req.Mod.Version}
+		}
+	}
+	if dep.Path == "" {
+		if dep, err = m.resolveFromGoSum(pkg); err != nil {
+			return DepModule{}, err
+		}
+	}
+	subdir := filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(pkg, dep.Path), "/"))
+
+	for _, replace := range file.Replace {
+		if replace.Old.Path != dep.Path || (replace.Old.Version != "" && replace.Old.Version != dep.Version) {
+			continue
+		}
+		if replace.New.Version == "" {
+			dir := filepath.FromSlash(replace.New.Path)
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(m.Dir, dir)
+			}
+			dep.Dir = filepath.Join(dir, subdir)
+			return dep, nil
+		}
+		dep.Path, dep.Version = replace.New.Path, replace.New.Version
+	}
+
+	cache := goModCache()
+	escapedPath, err := module.EscapePath(dep.Path)
+	if err != nil {
+		return DepModule{}, err
+	}
+	escapedVersion, err := module.EscapeVersion(dep.Version)
+	if err != nil {
+		return DepModule{}, err
+	}
+	moduleDir := filepath.Join(cache, escapedPath+"@"+escapedVersion)
+	if _, err := os.Stat(moduleDir); err != nil {
+		return DepModule{}, fmt.Errorf("%s@%s is not in the module cache %s (run go mod download %s@%s)",
+			dep.Path, dep.Version, cache, dep.Path, dep.Version)
+	}
+	dep.Dir = filepath.Join(moduleDir, subdir)
+	return dep, nil
+}
+
+// resolveFromGoSum picks the highest version of the module providing pkg
+// listed in go.sum, for dependencies go.mod does not mention
+func (m GoModule) resolveFromGoSum(pkg string) (DepModule, error) {
+	f, err := os.Open(filepath.Join(m.Dir, "go.sum"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DepModule{}, fmt.Errorf("%s is not a dependency of %s", pkg, m.Path)
+		}
+		return DepModule{}, err
+	}
+	defer f.Close()
+
+	var dep DepModule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") || !providesPackage(fields[0], pkg) {
+			continue
+		}
+		if len(fields[0]) > len(dep.Path) || (fields[0] == dep.Path && semver.Compare(fields[1], dep.Version) > 0) {
+			dep = DepModule{Path: fields[0], Version: fields[1]}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return DepModule{}, err
+	}
+	if dep.Path == "" {
+		return DepModule{}, fmt.Errorf("%s is not a dependency of %s", pkg, m.Path)
+	}
+	return dep, nil
+}
+
+func providesPackage(modulePath, pkg string) bool {
+	return pkg == modulePath || strings.HasPrefix(pkg, modulePath+"/")
+}
+
+// goModCache returns the module cache directory the go command would use
+func goModCache() string {
+	if cache := os.Getenv("GOMODCACHE"); cache != "" {
+		return cache
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		gopath = build.Default.GOPATH
+	}
+	return filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+}
+
+// depAPIFiles renders the exported API of every requested dependency as
+// virtual files under deps/<package>/
+func (mp *ManifestProcessor) depAPIFiles(ctx context.Context) ([]txtar.File, error) {
+	if len(mp.depAPI) == 0 {
+		return nil, nil
+	}
+
+	mod, err := ReadGoModule(mp.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading go.mod for --with-dep-api: %w", err)
+	}
+
+	var files []txtar.File
+	for _, pkg := range mp.depAPI {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		dep, err := mod.ResolveDep(pkg)
+		if err != nil {
+			return nil, err
+		}
+		mp.logger.V(1).Info("Outlining dependency API", "package", pkg, "module", dep.Path, "version", dep.Version, "dir", dep.Dir)
+
+		entries, err := os.ReadDir(dep.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s@%s: %w", dep.Path, dep.Version, err)
+		}
+		found := false
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			if match, err := build.Default.MatchFile(dep.Dir, name); err != nil || !match {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(dep.Dir, name))
+			if err != nil {
+				return nil, err
+			}
+			outline, err := outlineExportedGoFile(name, content)
+			if err != nil {
+				mp.logger.Info("Failed to parse dependency file, skipping it", "package", pkg, "file", name, "error", err.Error())
+				continue
+			}
+			if outline == nil {
+				continue
+			}
+			found = true
+			files = append(files, txtar.File{
+				Name: filepath.ToSlash(filepath.Join(depAPIDir, pkg, name)) + outlineSuffix,
+				Data: outline,
+			})
+		}
+		if !found {
+			return nil, fmt.Errorf("no exported Go declarations in %s@%s", pkg, dep.Version)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// outlineExportedGoFile renders only the exported declarations of a Go file,
+// without function bodies, keeping the doc comments of what remains. It
+// returns nil when the file exports nothing.
+func outlineExportedGoFile(relPath string, content []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, relPath, content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var imports []ast.Decl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			copied := *gen
+			copied.Specs = append([]ast.Spec(nil), gen.Specs...)
+			imports = append(imports, &copied)
+		}
+	}
+	ast.FileExports(file)
+
+	// FileExports drops imports and keeps methods of unexported types, which
+	// are not reachable
+	var decls []ast.Decl
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+			if recv, _, _ := strings.Cut(declName(fn), "."); !ast.IsExported(recv) {
+				continue
+			}
+		}
+		decls = append(decls, decl)
+	}
+	if len(decls) == 0 {
+		return nil, nil
+	}
+	file.Decls = append(imports, decls...)
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			d.Body = nil
+		case *ast.GenDecl:
+			ast.Inspect(d, func(n ast.Node) bool {
+				if lit, ok := n.(*ast.FuncLit); ok {
+					lit.Body = &ast.BlockStmt{Lbrace: lit.Body.Lbrace, Rbrace: lit.Body.Lbrace}
+					return false
+				}
+				return true
+			})
+		}
+	}
+
+	// Keep only the comments still attached to the remaining declarations
+	kept := make(map[*ast.CommentGroup]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if group, ok := n.(*ast.CommentGroup); ok {
+			kept[group] = true
+		}
+		return true
+	})
+	var comments []*ast.CommentGroup
+	for _, group := range file.Comments {
+		if kept[group] {
+			comments = append(comments, group)
+		}
+	}
+	file.Comments = comments
+
+	return printGoFile(fset, file)
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveDep(t *testing.T) {
+	cache := writeTestModule(t, map[string]string{
+		"example.com/!upper@v1.2.0/go.mod":     "module example.com/Upper\n",
+		"example.com/!upper@v1.2.0/sub/api.go": "package sub\n",
+		"example.com/summed@v0.3.0/api.go":     "package summed\n",
+	})
+	t.Setenv("GOMODCACHE", cache)
+
+	project := writeTestModule(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n\nrequire (\n\texample.com/Upper v1.2.0\n\texample.com/missing v0.1.0\n)\n\nreplace example.com/local => ./local\n\nrequire example.com/local v0.0.0\n",
+		"go.sum": "example.com/summed v0.2.0 h1:a=\nexample.com/summed v0.3.0 h1:b=\nexample.com/summed v0.4.0/go.mod h1:c=\n",
+	})
+	mod, err := ReadGoModule(project)
+	if err != nil {
+		t.Fatalf("ReadGoModule() error = %v", err)
+	}
+
+	tests := []struct {
+		pkg     string
+		version string
+		dir     string
+		wantErr string
+	}{
+		{pkg: "example.com/Upper/sub", version: "v1.2.0", dir: filepath.Join(cache, "example.com/!upper@v1.2.0/sub")},
+		{pkg: "example.com/summed", version: "v0.3.0", dir: filepath.Join(cache, "example.com/summed@v0.3.0")},
+		{pkg: "example.com/local/x", version: "v0.0.0", dir: filepath.Join(project, "local/x")},
+		{pkg: "example.com/missing", wantErr: "not in the module cache"},
+		{pkg: "example.com/unknown", wantErr: "not a dependency"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkg, func(t *testing.T) {
+			dep, err := mod.ResolveDep(tt.pkg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveDep() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveDep() error = %v", err)
+			}
+			if dep.Version != tt.version || dep.Dir != tt.dir {
+				t.Errorf("ResolveDep() = %+v, want version %s in %s", dep, tt.version, tt.dir)
+			}
+		})
+	}
+}
+
+func TestOutlineExportedGoFile(t *testing.T) {
+	got, err := outlineExportedGoFile("demo.go", []byte(symbolsSource+`
+type helper struct{}
+
+// Exported methods of unexported types are unreachable
+func (helper) Visible() {}
+`))
+	if err != nil {
+		t.Fatalf("outlineExportedGoFile() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"\"strings\"",
+		"// NewGreeter builds a Greeter\nfunc NewGreeter(name string) *Greeter\n",
+		"// Greet returns a greeting\nfunc (g *Greeter) Greet() string\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("outline missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"helper", "unreachable", "return &Greeter"} {
+		if strings.Contains(string(got), unwanted) {
+			t.Errorf("outline unexpectedly contains %q:\n%s", unwanted, got)
+		}
+	}
+
+	empty, err := outlineExportedGoFile("internal.go", []byte("package demo\n\nfunc internal() {}\n"))
+	if err != nil || empty != nil {
+		t.Errorf("outlineExportedGoFile() of unexported file = %q, %v, want nil", empty, err)
+	}
+}
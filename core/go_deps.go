@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GoModule describes the main module of a project, read offline from go.mod
+type GoModule struct {
+	Path string
+	Dir  string
+}
+
+// ReadGoModule reads the module path from dir/go.mod
+func ReadGoModule(dir string) (GoModule, error) {
+	return readGoModuleFS(os.DirFS(dir), dir)
+}
+
+// readGoModuleFS reads the module path from go.mod at the root of fsys,
+// recording dir as the module directory
+func readGoModuleFS(fsys fs.FS, dir string) (GoModule, error) {
+	f, err := fsys.Open("go.mod")
+	if err != nil {
+		return GoModule{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			path := strings.TrimSpace(rest)
+			if unquoted, err := strconv.Unquote(path); err == nil {
+				path = unquoted
+			}
+			return GoModule{Path: path, Dir: dir}, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return GoModule{}, err
+	}
+	return GoModule{}, fmt.Errorf("no module directive in %s", filepath.Join(dir, "go.mod"))
+}
+
+// packageDir maps an import path to the package directory relative to the
+// module root, reporting false for packages outside the module
+func (m GoModule) packageDir(importPath string) (string, bool) {
+	if importPath == m.Path {
+		return ".", true
+	}
+	rel, ok := strings.CutPrefix(importPath, m.Path+"/")
+	if !ok {
+		return "", false
+	}
+	return filepath.FromSlash(rel), true
+}
+
+// goPackage holds the files and in-module imports of one package directory
+type goPackage struct {
+	files       []string
+	testFiles   []string
+	imports     []string
+	testImports []string
+}
+
+// loadGoPackage parses the import clauses of every Go file in dir
+func (m GoModule) loadGoPackage(dir string) (goPackage, error) {
+	var pkg goPackage
+	entries, err := os.ReadDir(filepath.Join(m.Dir, dir))
+	if err != nil {
+		return pkg, err
+	}
+
+	imports := make(map[string]bool)
+	testImports := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" {
+			continue
+		}
+		file := filepath.Join(dir, name)
+		parsed, err := parser.ParseFile(fset, filepath.Join(m.Dir, file), nil, parser.ImportsOnly)
+		if err != nil {
+			return pkg, err
+		}
+
+		isTest := strings.HasSuffix(name, "_test.go")
+		if isTest {
+			pkg.testFiles = append(pkg.testFiles, file)
+		} else {
+			pkg.files = append(pkg.files, file)
+		}
+		for _, spec := range parsed.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			importDir, ok := m.packageDir(importPath)
+			if !ok || importDir == dir {
+				continue
+			}
+			if isTest {
+				testImports[importDir] = true
+			} else {
+				imports[importDir] = true
+			}
+		}
+	}
+
+	pkg.imports = sortedKeys(imports)
+	pkg.testImports = sortedKeys(testImports)
+	return pkg, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GoDepsClosure returns the Go files of the target packages and of every
+// in-module package they transitively import, relative to the module root.
+// depth limits how many import hops are followed (0 means no limit); with
+// includeTests the _test.go files of every selected package are added and
+// the test imports of the targets are followed too.
+func (m GoModule) GoDepsClosure(targets []string, includeTests bool, depth int) ([]string, error) {
+	type queued struct {
+		dir   string
+		depth int
+	}
+
+	var queue []queued
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		dir := filepath.Clean(target)
+		if filepath.IsAbs(dir) {
+			rel, err := filepath.Rel(m.Dir, dir)
+			if err != nil {
+				return nil, err
+			}
+			dir = rel
+		}
+		if dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("package %s is outside module %s", target, m.Path)
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			queue = append(queue, queued{dir: dir})
+		}
+	}
+	isTarget := make(map[string]bool, len(seen))
+	for dir := range seen {
+		isTarget[dir] = true
+	}
+
+	var files []string
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		pkg, err := m.loadGoPackage(next.dir)
+		if err != nil {
+			return nil, fmt.Errorf("error loading package %s: %w", next.dir, err)
+		}
+		files = append(files, pkg.files...)
+		imports := pkg.imports
+		if includeTests {
+			files = append(files, pkg.testFiles...)
+			if isTarget[next.dir] {
+				imports = append(imports, pkg.testImports...)
+			}
+		}
+
+		if depth > 0 && next.depth >= depth {
+			continue
+		}
+		for _, dir := range imports {
+			if !seen[dir] {
+				seen[dir] = true
+				queue = append(queue, queued{dir: dir, depth: next.depth + 1})
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestGoDepsClosure(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":               "module example.com/app\n\ngo 1.21\n",
+		"main.go":              "package main\n\nimport \"example.com/app/cmd\"\n\nfunc main() { cmd.Run() }\n",
+		"cmd/root.go":          "package cmd\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/core\"\n)\n\nfunc Run() { fmt.Println(core.X) }\n",
+		"cmd/root_test.go":     "package cmd\n\nimport \"example.com/app/testutil\"\n\nvar _ = testutil.Y\n",
+		"core/core.go":         "package core\n\nimport \"example.com/app/internal/logger\"\n\nvar X = logger.Z\n",
+		"core/core_test.go":    "package core\n",
+		"internal/logger/l.go": "package logger\n\nvar Z = 1\n",
+		"testutil/util.go":     "package testutil\n\nvar Y = 2\n",
+		"unused/unused.go":     "package unused\n",
+	})
+
+	module, err := ReadGoModule(dir)
+	if err != nil {
+		t.Fatalf("ReadGoModule() error = %v", err)
+	}
+	if module.Path != "example.com/app" {
+		t.Errorf("module.Path = %q, want example.com/app", module.Path)
+	}
+
+	tests := []struct {
+		name         string
+		includeTests bool
+		depth        int
+		want         []string
+	}{
+		{
+			name: "Full closure",
+			want: []string{"cmd/root.go", "core/core.go", "internal/logger/l.go"},
+		},
+		{
+			name:  "Depth one",
+			depth: 1,
+			want:  []string{"cmd/root.go", "core/core.go"},
+		},
+		{
+			name:         "With tests",
+			includeTests: true,
+			want: []string{
+				"cmd/root.go", "cmd/root_test.go",
+				"core/core.go", "core/core_test.go",
+				"internal/logger/l.go", "testutil/util.go",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := module.GoDepsClosure([]string{"./cmd"}, tt.includeTests, tt.depth)
+			if err != nil {
+				t.Fatalf("GoDepsClosure() error = %v", err)
+			}
+			want := make([]string, len(tt.want))
+			for i, file := range tt.want {
+				want[i] = filepath.FromSlash(file)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GoDepsClosure() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestEnableFiles(t *testing.T) {
+	mg := NewManifestGenerator(testLogger(t))
+	mockWriter := &MockManifestWriter{ManifestData: Manifest{FileList: map[string]bool{
+		"a.go": true,
+		"b.go": false,
+		"c.go": true,
+	}}}
+	mg.reader = mockWriter
+	mg.writer = mockWriter
+
+	enabled, err := mg.EnableFiles(".nearwait.yml", []string{"a.go", "b.go", "d.go"})
+	if err != nil {
+		t.Fatalf("EnableFiles() error = %v", err)
+	}
+	if enabled != 2 {
+		t.Errorf("EnableFiles() enabled %d files, want 2", enabled)
+	}
+
+	want := "filelist:\n- a.go\n- b.go\n# - c.go\n- d.go\n"
+	if mockWriter.ManifestContent != want {
+		t.Errorf("Manifest = %q, want %q", mockWriter.ManifestContent, want)
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ModeFull renders an entry in full, even when outlining is the default
+	ModeFull = "full"
+	// ModeOutline renders only the API surface of a Go file
+	ModeOutline = "outline"
+)
+
+// outlineSuffix is appended to the section name of outlined files
+const outlineSuffix = " (outline)"
+
+// noteMode extracts the "mode: outline" setting from a manifest note
+func noteMode(note string) string {
+	for _, item := range strings.Split(note, ",") {
+		key, value, ok := strings.Cut(item, ":")
+		if ok && strings.TrimSpace(key) == "mode" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// WithOutline makes outline the default mode for Go files whose entry does
+// not ask for "mode: full"
+func (mp *ManifestProcessor) WithOutline(outline bool) *ManifestProcessor {
+	mp.outline = outline
+	return mp
+}
+
+func (mp *ManifestProcessor) outlineFor(relPath string) bool {
+	if filepath.Ext(relPath) != ".go" {
+		return false
+	}
+	switch noteMode(mp.manifest.Notes[relPath]) {
+	case ModeOutline:
+		return true
+	case ModeFull:
+		return false
+	}
+	return mp.outline
+}
+
+// outlineGoFile renders the package clause, imports, declarations and
+// func/method signatures of a Go file with their doc comments, dropping
+// every function body
+func outlineGoFile(relPath string, content []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, relPath, content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodies []*ast.BlockStmt
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Body != nil {
+				bodies = append(bodies, d.Body)
+				d.Body = nil
+			}
+		case *ast.GenDecl:
+			// Function literals in initializers, such as cobra RunE hooks,
+			// are implementation too
+			ast.Inspect(d, func(n ast.Node) bool {
+				if lit, ok := n.(*ast.FuncLit); ok {
+					bodies = append(bodies, lit.Body)
+					lit.Body = &ast.BlockStmt{Lbrace: lit.Body.Lbrace, Rbrace: lit.Body.Lbrace}
+					return false
+				}
+				return true
+			})
+		}
+	}
+
+	// Drop the comments that lived inside the removed bodies
+	var comments []*ast.CommentGroup
+	for _, group := range file.Comments {
+		inside := false
+		for _, body := range bodies {
+			if group.Pos() > body.Lbrace && group.End() <= body.Rbrace {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			comments = append(comments, group)
+		}
+	}
+	file.Comments = comments
+
+	return printGoFile(fset, file)
+}
+
+func printGoFile(fset *token.FileSet, file *ast.File) ([]byte, error) {
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := cfg.Fprint(&buf, fset, file); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
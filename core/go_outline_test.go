@@ -0,0 +1,63 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutlineGoFile(t *testing.T) {
+	got, err := outlineGoFile("demo.go", []byte(symbolsSource+`
+var handler = func() {
+	// inside a literal
+	fmt.Println("body")
+}
+`))
+	if err != nil {
+		t.Fatalf("outlineGoFile() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"package demo",
+		"\"strings\"",
+		"// Greeter says hello\ntype Greeter struct {\n\tName string\n}",
+		"// NewGreeter builds a Greeter\nfunc NewGreeter(name string) *Greeter\n",
+		"// Greet returns a greeting\nfunc (g *Greeter) Greet() string\n",
+		"var handler = func() {}",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("outline missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"return &Greeter", "Sprintf", "inside a literal"} {
+		if strings.Contains(string(got), unwanted) {
+			t.Errorf("outline unexpectedly contains %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestOutlineFor(t *testing.T) {
+	mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml")
+	mp.manifest = Manifest{Notes: map[string]string{
+		"api.go":   "mode: outline",
+		"focus.go": "binary, mode: full",
+	}}
+
+	tests := []struct {
+		path    string
+		outline bool
+		want    bool
+	}{
+		{"api.go", false, true},
+		{"other.go", false, false},
+		{"other.go", true, true},
+		{"focus.go", true, false},
+		{"README.md", true, false},
+	}
+
+	for _, tt := range tests {
+		mp.WithOutline(tt.outline)
+		if got := mp.outlineFor(tt.path); got != tt.want {
+			t.Errorf("outlineFor(%q) with outline=%v = %v, want %v", tt.path, tt.outline, got, tt.want)
+		}
+	}
+}
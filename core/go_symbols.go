@@ -0,0 +1,221 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// splitSymbols splits a manifest entry such as "core/processor.go#ManifestProcessor.Process"
+// or "core#ClipboardWriter,SystemClipboard" into its path and Go symbols.
+// Entries that do not name a Go file or directory followed by Go identifiers,
+// such as "docs/C#.md", are plain paths.
+func splitSymbols(entry string) (string, []string) {
+	i := strings.LastIndex(entry, "#")
+	if i <= 0 || i == len(entry)-1 {
+		return entry, nil
+	}
+	path := entry[:i]
+	// Line ranges come before the symbols, e.g. "main.go:1-5#main", so that
+	// ReadManifest can reject the combination
+	if ext := filepath.Ext(rangeSpec.ReplaceAllString(path, "")); ext != ".go" && ext != "" {
+		return entry, nil
+	}
+	var symbols []string
+	for _, symbol := range strings.Split(entry[i+1:], ",") {
+		if symbol = strings.TrimSpace(symbol); symbol == "" {
+			continue
+		}
+		if !isSymbolName(symbol) {
+			return entry, nil
+		}
+		symbols = append(symbols, symbol)
+	}
+	if len(symbols) == 0 {
+		return entry, nil
+	}
+	return path, symbols
+}
+
+// isSymbolName reports whether symbol names a Go declaration: "Ident",
+// "Type.Method" or "Type.*"
+func isSymbolName(symbol string) bool {
+	name, member, isMember := strings.Cut(symbol, ".")
+	if !token.IsIdentifier(name) {
+		return false
+	}
+	return !isMember || member == "*" || token.IsIdentifier(member)
+}
+
+// goPackageFiles lists the non-test Go files among the entries of the
+// package directory dir
+func goPackageFiles(dir string, entries []fs.DirEntry) []string {
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	return files
+}
+
+// symbolsFor returns the Go symbols requested for relPath, either on the file
+// entry itself or on an enabled entry for its package directory. fromPackage
+// reports whether the file is only included through its package entry.
+func (mp *ManifestProcessor) symbolsFor(relPath string) (symbols []string, fromPackage bool) {
+	isCommented, listed := mp.manifest.FileList[relPath]
+	fileEnabled := listed && !isCommented
+	if fileEnabled && len(mp.manifest.Symbols[relPath]) == 0 {
+		return nil, false
+	}
+
+	symbols = append(symbols, mp.manifest.Symbols[relPath]...)
+	dir := filepath.Dir(relPath)
+	if isCommented, ok := mp.manifest.FileList[dir]; ok && !isCommented {
+		for _, symbol := range mp.manifest.Symbols[dir] {
+			if _, dup := matchesAny(symbols, symbol); !dup {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	return symbols, !fileEnabled
+}
+
+// declName returns the manifest name of a top-level declaration, e.g.
+// "NewManifestProcessor" or "ManifestProcessor.Process"
+func declName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *ast.StarExpr:
+			recv = t.X
+			continue
+		case *ast.IndexExpr:
+			recv = t.X
+			continue
+		case *ast.IndexListExpr:
+			recv = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+}
+
+func symbolMatches(symbol, name string) bool {
+	if symbol == name {
+		return true
+	}
+	// "Type.*" selects every method of Type
+	if typeName, ok := strings.CutSuffix(symbol, ".*"); ok {
+		return strings.HasPrefix(name, typeName+".")
+	}
+	return false
+}
+
+func matchesAny(symbols []string, name string) (string, bool) {
+	for _, symbol := range symbols {
+		if symbolMatches(symbol, name) {
+			return symbol, true
+		}
+	}
+	return "", false
+}
+
+// extractGoSymbols renders the package clause, the imports and the requested
+// top-level declarations of a Go file, each with its doc comment. It returns
+// the requested symbols that were found in the file.
+func extractGoSymbols(relPath string, content []byte, symbols []string) ([]byte, []string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, relPath, content, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source := func(from, to token.Pos) string {
+		return string(content[fset.Position(from).Offset:fset.Position(to).Offset])
+	}
+	withDoc := func(doc *ast.CommentGroup, from token.Pos) token.Pos {
+		if doc != nil {
+			return doc.Pos()
+		}
+		return from
+	}
+
+	var decls []string
+	found := make(map[string]bool)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if symbol, ok := matchesAny(symbols, declName(d)); ok {
+				found[symbol] = true
+				decls = append(decls, source(withDoc(d.Doc, d.Pos()), d.End()))
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				var names []string
+				var doc *ast.CommentGroup
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names, doc = []string{s.Name.Name}, s.Doc
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						names = append(names, name.Name)
+					}
+					doc = s.Doc
+				}
+				for _, name := range names {
+					symbol, ok := matchesAny(symbols, name)
+					if !ok {
+						continue
+					}
+					found[symbol] = true
+					if !d.Lparen.IsValid() {
+						// A single-spec declaration carries its doc on the GenDecl
+						decls = append(decls, source(withDoc(d.Doc, d.Pos()), d.End()))
+					} else {
+						if doc != nil {
+							decls = append(decls, source(doc.Pos(), doc.End())+"\n"+d.Tok.String()+" "+source(spec.Pos(), spec.End()))
+						} else {
+							decls = append(decls, d.Tok.String()+" "+source(spec.Pos(), spec.End()))
+						}
+					}
+					break
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n", file.Name.Name)
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			buf.WriteString("\n" + source(d.Pos(), d.End()) + "\n")
+		}
+	}
+	for _, decl := range decls {
+		buf.WriteString("\n" + decl + "\n")
+	}
+
+	var foundSymbols []string
+	for _, symbol := range symbols {
+		if found[symbol] {
+			foundSymbols = append(foundSymbols, symbol)
+		}
+	}
+	return buf.Bytes(), foundSymbols, nil
+}
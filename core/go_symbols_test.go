@@ -0,0 +1,158 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const symbolsSource = `package demo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Greeter says hello
+type Greeter struct {
+	Name string
+}
+
+type (
+	// Loud shouts
+	Loud bool
+	Quiet bool
+)
+
+// NewGreeter builds a Greeter
+func NewGreeter(name string) *Greeter {
+	return &Greeter{Name: name}
+}
+
+// Greet returns a greeting
+func (g *Greeter) Greet() string {
+	return fmt.Sprintf("hello %s", strings.TrimSpace(g.Name))
+}
+
+func (g *Greeter) Wave() {}
+`
+
+func TestSplitSymbols(t *testing.T) {
+	tests := []struct {
+		entry       string
+		wantPath    string
+		wantSymbols []string
+	}{
+		{"core/processor.go", "core/processor.go", nil},
+		{"core/processor.go#ManifestProcessor.Process", "core/processor.go", []string{"ManifestProcessor.Process"}},
+		{"core#ClipboardWriter,SystemClipboard", "core", []string{"ClipboardWriter", "SystemClipboard"}},
+		{"core#", "core#", nil},
+		{"core#Greeter.*", "core", []string{"Greeter.*"}},
+		{"docs/C#.md", "docs/C#.md", nil},
+		{"notes/#1 ideas.txt", "notes/#1 ideas.txt", nil},
+		{"core/x.go#not-a-symbol", "core/x.go#not-a-symbol", nil},
+		{"core/x.go#A.B.C", "core/x.go#A.B.C", nil},
+		{"core/x.go:1-5#Run", "core/x.go:1-5", []string{"Run"}},
+	}
+
+	for _, tt := range tests {
+		path, symbols := splitSymbols(tt.entry)
+		if path != tt.wantPath || !reflect.DeepEqual(symbols, tt.wantSymbols) {
+			t.Errorf("splitSymbols(%q) = %q, %v, want %q, %v", tt.entry, path, symbols, tt.wantPath, tt.wantSymbols)
+		}
+	}
+}
+
+func TestExtractGoSymbols(t *testing.T) {
+	tests := []struct {
+		name      string
+		symbols   []string
+		wantFound []string
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name:      "Method with doc comment",
+			symbols:   []string{"Greeter.Greet"},
+			wantFound: []string{"Greeter.Greet"},
+			contains:  []string{"package demo\n", "\"fmt\"", "// Greet returns a greeting\nfunc (g *Greeter) Greet() string {"},
+			excludes:  []string{"NewGreeter", "Wave"},
+		},
+		{
+			name:      "Type and all its methods",
+			symbols:   []string{"Greeter", "Greeter.*"},
+			wantFound: []string{"Greeter", "Greeter.*"},
+			contains:  []string{"// Greeter says hello\ntype Greeter struct", "func (g *Greeter) Greet()", "func (g *Greeter) Wave()"},
+			excludes:  []string{"NewGreeter"},
+		},
+		{
+			name:      "Spec from a grouped declaration",
+			symbols:   []string{"Loud", "Missing"},
+			wantFound: []string{"Loud"},
+			contains:  []string{"// Loud shouts\ntype Loud bool"},
+			excludes:  []string{"Quiet"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := extractGoSymbols("demo.go", []byte(symbolsSource), tt.symbols)
+			if err != nil {
+				t.Fatalf("extractGoSymbols() error = %v", err)
+			}
+			if !reflect.DeepEqual(found, tt.wantFound) {
+				t.Errorf("found = %v, want %v", found, tt.wantFound)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %q:\n%s", want, got)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(string(got), unwanted) {
+					t.Errorf("output unexpectedly contains %q:\n%s", unwanted, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderFilePackageSymbols(t *testing.T) {
+	mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml")
+	mp.manifest = Manifest{
+		FileList: map[string]bool{"demo": false},
+		Symbols:  map[string][]string{"demo": {"NewGreeter"}},
+	}
+
+	section, ok := mp.renderFile("demo/greeter.go", []byte(symbolsSource))
+	if !ok || section.Name != "demo/greeter.go#NewGreeter" {
+		t.Errorf("renderFile() = %q, %v, want demo/greeter.go#NewGreeter", section.Name, ok)
+	}
+
+	if _, ok := mp.renderFile("demo/other.go", []byte("package demo\n\nfunc other() {}\n")); ok {
+		t.Error("Expected package file without requested symbols to be skipped")
+	}
+}
+
+func TestGenerateKeepsHashInFileName(t *testing.T) {
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	if err := os.WriteFile(manifestFile, []byte("filelist:\n- docs/C#.md\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	generator := NewManifestGenerator(testLogger(t)).WithFS(fstest.MapFS{
+		"docs/C#.md": {Data: []byte("# C#\n")},
+	})
+	if _, err := generator.Generate(false, manifestFile); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	manifest, err := generator.ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{filepath.Join("docs", "C#.md"): false}; !reflect.DeepEqual(manifest.FileList, want) {
+		t.Errorf("FileList = %v, want %v", manifest.FileList, want)
+	}
+}
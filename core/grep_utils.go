@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// GrepMatch is a line of a project file that matches a search pattern
+type GrepMatch struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Location returns the position of the match
+func (m GrepMatch) Location() SourceLocation {
+	return SourceLocation{Path: m.Path, Lines: LineRange{Start: m.Line, End: m.Line}}
+}
+
+// Grep searches every file the walker lists, honoring excludes, for lines
+// matching re. Binary files and nearwait's own manifest and archive are
+// skipped.
+func (mg *ManifestGenerator) Grep(manifestFile string, re *regexp.Regexp) ([]GrepMatch, error) {
+	manifest, err := mg.ReadManifest(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer mg.closeMounts()
+	if err := mg.applyMounts(manifest.Settings); err != nil {
+		return nil, err
+	}
+	currentFiles, err := mg.GetCurrentFiles()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(currentFiles))
+	for file := range currentFiles {
+		if !IsOwnFile(manifestFile, file) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	var matches []GrepMatch
+	for _, file := range files {
+		content, err := fs.ReadFile(mg.fsys, filepath.ToSlash(file))
+		if err != nil {
+			mg.logger.V(1).Info("Skipping unreadable file", "path", file, "error", err.Error())
+			continue
+		}
+		if isBinary(content) {
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+		for line := 1; scanner.Scan(); line++ {
+			if re.Match(scanner.Bytes()) {
+				matches = append(matches, GrepMatch{Path: file, Line: line, Text: scanner.Text()})
+			}
+		}
+	}
+	return matches, nil
+}
+
+// DisableAll comments out every entry of the manifest
+func (mg *ManifestGenerator) DisableAll(manifestFile string) error {
+	manifest, err := mg.reader.ReadManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	for file := range manifest.FileList {
+		manifest.FileList[file] = true
+	}
+	return mg.writer.WriteManifest(manifest, manifestFile)
+}
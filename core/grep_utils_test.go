@@ -0,0 +1,53 @@
+package core
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGrep(t *testing.T) {
+	fsys := fstest.MapFS{
+		"core/processor.go":    {Data: []byte("package core\n\ntype ClipboardWriter interface {\n\tShouldDelay() bool\n}\n")},
+		"core/batch.go":        {Data: []byte("package core\n")},
+		"README.md":            {Data: []byte("Uses a ClipboardWriter\n")},
+		"logo.png":             {Data: []byte("\x89PNG\r\n\x1a\nClipboardWriter")},
+		".nearwait.yml":        {Data: []byte("filelist:\n- ClipboardWriter\n")},
+		".nearwait.txtar":      {Data: []byte("-- core/processor.go --\nClipboardWriter\n")},
+		".git/config":          {Data: []byte("ClipboardWriter\n")},
+		"node_modules/x/a.txt": {Data: []byte("ClipboardWriter\n")},
+	}
+	mg := NewManifestGenerator(testLogger(t)).WithFS(fsys)
+
+	matches, err := mg.Grep(".nearwait.yml", regexp.MustCompile(`ClipboardWriter|ShouldDelay`))
+	if err != nil {
+		t.Fatalf("Grep() error = %v", err)
+	}
+
+	want := []GrepMatch{
+		{Path: "README.md", Line: 1, Text: "Uses a ClipboardWriter"},
+		{Path: "core/processor.go", Line: 3, Text: "type ClipboardWriter interface {"},
+		{Path: "core/processor.go", Line: 4, Text: "\tShouldDelay() bool"},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("Grep() = %v, want %v", matches, want)
+	}
+}
+
+func TestDisableAll(t *testing.T) {
+	mg := NewManifestGenerator(testLogger(t))
+	mockWriter := &MockManifestWriter{ManifestData: Manifest{FileList: map[string]bool{
+		"a.go": false,
+		"b.go": true,
+	}}}
+	mg.reader = mockWriter
+	mg.writer = mockWriter
+
+	if err := mg.DisableAll(".nearwait.yml"); err != nil {
+		t.Fatalf("DisableAll() error = %v", err)
+	}
+	if want := "filelist:\n# - a.go\n# - b.go\n"; mockWriter.ManifestContent != want {
+		t.Errorf("Manifest = %q, want %q", mockWriter.ManifestContent, want)
+	}
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// Source prefixes naming container images rather than archives
+const (
+	OCISourcePrefix           = "oci:"
+	DockerArchiveSourcePrefix = "docker-archive:"
+)
+
+// ociRefNameAnnotation holds the tag of an image in an OCI layout index
+const ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// OpenSourceFS serves a project source as a read-only file system: a
+// container image named "oci:<layout dir>[:<tag>]" or
+// "docker-archive:<tarball>[:<tag>]", or else an archive
+func OpenSourceFS(logger logr.Logger, source string) (fs.FS, error) {
+	if strings.HasPrefix(source, OCISourcePrefix) || strings.HasPrefix(source, DockerArchiveSourcePrefix) {
+		return OpenImageFS(logger, source)
+	}
+	return OpenArchiveFS(logger, source)
+}
+
+// OpenImageFS flattens the layers of a local container image into a
+// read-only file system held in memory, applying whiteouts. Nothing is
+// pulled from a registry. Links are resolved as in OpenArchiveFS, and files
+// are subject to the same size caps.
+func OpenImageFS(logger logr.Logger, ref string) (fs.FS, error) {
+	var img v1.Image
+	switch {
+	case strings.HasPrefix(ref, OCISourcePrefix):
+		dir, tag := splitImageRef(strings.TrimPrefix(ref, OCISourcePrefix))
+		index, err := layout.ImageIndexFromPath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading OCI layout %s: %w", dir, err)
+		}
+		if img, err = layoutImage(index, tag); err != nil {
+			return nil, fmt.Errorf("error reading OCI layout %s: %w", dir, err)
+		}
+	case strings.HasPrefix(ref, DockerArchiveSourcePrefix):
+		file, tag := splitImageRef(strings.TrimPrefix(ref, DockerArchiveSourcePrefix))
+		var tagRef *name.Tag
+		if tag != "" {
+			t, err := name.NewTag(tag)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag %s: %w", tag, err)
+			}
+			tagRef = &t
+		}
+		var err error
+		if img, err = tarball.ImageFromPath(file, tagRef); err != nil {
+			return nil, fmt.Errorf("error reading docker archive %s: %w", file, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported image reference %s: want %s or %s", ref, OCISourcePrefix, DockerArchiveSourcePrefix)
+	}
+
+	rootfs := mutate.Extract(img)
+	defer rootfs.Close()
+	return tarFS(logger, rootfs, ref)
+}
+
+// splitImageRef separates the path of an image from an optional tag. Tags may
+// contain colons themselves, as in image.tar:alpine:3.19, so the path is the
+// shortest prefix that exists.
+func splitImageRef(ref string) (path, tag string) {
+	for i, c := range ref {
+		if c != ':' {
+			continue
+		}
+		if _, err := os.Stat(ref[:i]); err == nil {
+			return ref[:i], ref[i+1:]
+		}
+	}
+	return ref, ""
+}
+
+// layoutImage picks the image tagged tag from an OCI layout, or its only
+// image when tag is empty. Multi-platform indexes resolve to the linux image
+// for the current architecture.
+func layoutImage(index v1.ImageIndex, tag string) (v1.Image, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []v1.Descriptor
+	for _, desc := range manifest.Manifests {
+		refName := desc.Annotations[ociRefNameAnnotation]
+		if tag == "" || refName == tag || strings.HasSuffix(refName, ":"+tag) {
+			matches = append(matches, desc)
+		}
+	}
+	switch {
+	case len(matches) == 0 && tag != "":
+		return nil, fmt.Errorf("no image tagged %s", tag)
+	case len(matches) == 0:
+		return nil, fmt.Errorf("layout holds no images")
+	case len(matches) > 1 && tag == "":
+		return nil, fmt.Errorf("layout holds %d images, name one with %s<dir>:<tag>", len(matches), OCISourcePrefix)
+	}
+	return descriptorImage(index, matches[0])
+}
+
+func descriptorImage(index v1.ImageIndex, desc v1.Descriptor) (v1.Image, error) {
+	switch {
+	case desc.MediaType.IsImage():
+		return index.Image(desc.Digest)
+	case desc.MediaType.IsIndex():
+		child, err := index.ImageIndex(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := child.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		want := v1.Platform{OS: "linux", Architecture: runtime.GOARCH}
+		for _, platformDesc := range manifest.Manifests {
+			if platformDesc.Platform != nil && platformDesc.Platform.Satisfies(want) {
+				return descriptorImage(child, platformDesc)
+			}
+		}
+		if len(manifest.Manifests) == 1 {
+			return descriptorImage(child, manifest.Manifests[0])
+		}
+		return nil, fmt.Errorf("no image for %s in index %s", want.String(), desc.Digest)
+	}
+	return nil, fmt.Errorf("unsupported media type %s", desc.MediaType)
+}
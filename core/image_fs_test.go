@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// testImage builds a two-layer image whose second layer deletes
+// etc/old.conf with a whiteout
+func testImage(t *testing.T) v1.Image {
+	t.Helper()
+	layer := func(files map[string]string) v1.Layer {
+		t.Helper()
+		data := writeTar(t, files, false)
+		l, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	img, err := mutate.AppendLayers(empty.Image,
+		layer(map[string]string{"etc/app.conf": "port = 80\n", "etc/old.conf": "old\n"}),
+		layer(map[string]string{"etc/.wh.old.conf": "", "etc/app.conf": "port = 8080\n", "app/main.go": "package main\n"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestOpenImageFS(t *testing.T) {
+	img := testImage(t)
+	dir := t.TempDir()
+
+	layoutDir := filepath.Join(dir, "layout")
+	path, err := layout.Write(layoutDir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := path.AppendImage(img, layout.WithAnnotations(map[string]string{ociRefNameAnnotation: "v1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(dir, "image.tar")
+	tag, err := name.NewTag("example.com/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tarball.WriteToFile(archive, tag, img); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr string
+	}{
+		{name: "oci layout", ref: "oci:" + layoutDir},
+		{name: "oci layout tag", ref: "oci:" + layoutDir + ":v1"},
+		{name: "oci layout missing tag", ref: "oci:" + layoutDir + ":v2", wantErr: "no image tagged v2"},
+		{name: "docker archive", ref: "docker-archive:" + archive},
+		{name: "docker archive tag", ref: "docker-archive:" + archive + ":example.com/app:v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys, err := OpenSourceFS(testLogger(t), tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("OpenSourceFS() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenSourceFS() error = %v", err)
+			}
+
+			var files []string
+			err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					files = append(files, path)
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WalkDir() error = %v", err)
+			}
+			if want := []string{"app/main.go", "etc/app.conf"}; !reflect.DeepEqual(files, want) {
+				t.Errorf("files = %v, want %v", files, want)
+			}
+			if data, _ := fs.ReadFile(fsys, "etc/app.conf"); string(data) != "port = 8080\n" {
+				t.Errorf("etc/app.conf = %q, want the upper layer", data)
+			}
+		})
+	}
+}
+
+func TestSplitImageRef(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		ref      string
+		wantPath string
+		wantTag  string
+	}{
+		{ref: dir, wantPath: dir},
+		{ref: dir + ":v1", wantPath: dir, wantTag: "v1"},
+		{ref: dir + ":alpine:3.19", wantPath: dir, wantTag: "alpine:3.19"},
+		{ref: "missing:v1", wantPath: "missing:v1"},
+	}
+	for _, tt := range tests {
+		path, tag := splitImageRef(tt.ref)
+		if path != tt.wantPath || tag != tt.wantTag {
+			t.Errorf("splitImageRef(%q) = %q, %q, want %q, %q", tt.ref, path, tag, tt.wantPath, tt.wantTag)
+		}
+	}
+}
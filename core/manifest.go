@@ -10,6 +10,17 @@ import (
 
 type Manifest struct {
 	FileList map[string]bool
+	// Notes holds the trailing comment written after an entry, e.g. "binary"
+	Notes map[string]string
+	// Ranges restricts an entry to the listed lines, e.g. "core/processor.go:95-204"
+	Ranges map[string][]LineRange
+	// Symbols restricts a Go file or package entry to the listed declarations,
+	// e.g. "core/processor.go#ManifestProcessor.Process" or "core#ClipboardWriter"
+	Symbols map[string][]string
+	// Settings holds top-level "key: value" options such as "pair_tests: true"
+	Settings map[string]string
+	// Order lists the entries in the order they appear in the manifest file
+	Order []string
 }
 
 type ManifestReader interface {
@@ -37,6 +48,9 @@ type ManifestGenerator struct {
 	updater        ManifestUpdater
 	walker         FileSystemWalker
 	fsys           fs.FS
+	baseFS         fs.FS
+	mounts         dirRoots
+	dir            string
 	excludesActive bool
 }
 
@@ -59,6 +73,7 @@ func NewManifestGenerator(logger logr.Logger) *ManifestGenerator {
 		},
 		includeDirs:    make(map[string]bool),
 		fsys:           nil,
+		dir:            ".",
 		excludesActive: true,
 	}
 	mg.reader = mg
@@ -83,9 +98,44 @@ func (mg *ManifestGenerator) WithIncludes(includes []string) *ManifestGenerator
 
 func (mg *ManifestGenerator) WithFS(fsys fs.FS) *ManifestGenerator {
 	mg.fsys = fsys
+	mg.baseFS = fsys
 	return mg
 }
 
+// WithDir sets the project directory that manifest entries and relative
+// mount directories are resolved against, the working directory by default
+func (mg *ManifestGenerator) WithDir(dir string) *ManifestGenerator {
+	mg.dir = dir
+	return mg
+}
+
+// applyMounts serves the directories declared by the mounts setting next to
+// the project files until closeMounts is called
+func (mg *ManifestGenerator) applyMounts(settings map[string]string) error {
+	if mg.baseFS == nil {
+		return nil
+	}
+	mg.closeMounts()
+	mounts, roots, err := mountSettings(settings, mg.dir)
+	mg.mounts = roots
+	if err != nil {
+		return err
+	}
+	mg.fsys, err = newMountFS(mg.baseFS, mounts)
+	return err
+}
+
+// closeMounts releases the directories opened by applyMounts, leaving the
+// project files alone
+func (mg *ManifestGenerator) closeMounts() {
+	if err := mg.mounts.Close(); err != nil {
+		mg.logger.V(1).Info("Failed to close mounted directory", "error", err.Error())
+	}
+	if mg.baseFS != nil {
+		mg.fsys = mg.baseFS
+	}
+}
+
 func (mg *ManifestGenerator) isExcluded(path string) bool {
 	if path == "." {
 		return false
@@ -11,6 +11,17 @@ func (mg *ManifestGenerator) Generate(force bool, manifestFile string) (bool, er
 		return false, fmt.Errorf("nil filesystem")
 	}
 
+	// The existing manifest is read first since its mounts decide which
+	// files there are
+	existing, err := mg.reader.ReadManifest(manifestFile)
+	if err != nil {
+		return false, fmt.Errorf("error reading manifest: %w", err)
+	}
+	defer mg.closeMounts()
+	if err := mg.applyMounts(existing.Settings); err != nil {
+		return false, fmt.Errorf("error mounting directories: %w", err)
+	}
+
 	currentFiles, err := mg.walker.GetCurrentFiles()
 	if err != nil {
 		return false, fmt.Errorf("error getting current files: %w", err)
@@ -18,17 +29,18 @@ func (mg *ManifestGenerator) Generate(force bool, manifestFile string) (bool, er
 
 	var manifest Manifest
 	isNewManifest := true
+	// Only entries new to the manifest are sniffed for binary content and
+	// commented when sensitive
+	known := existing.FileList
 
 	if !force {
-		manifest, err = mg.reader.ReadManifest(manifestFile)
-		if err != nil {
-			return false, fmt.Errorf("error reading manifest: %w", err)
-		}
+		manifest = existing
 		isNewManifest = len(manifest.FileList) == 0
 	}
 
 	if force || isNewManifest {
-		manifest = Manifest{FileList: make(map[string]bool)}
+		manifest = Manifest{FileList: make(map[string]bool), Notes: make(map[string]string), Settings: existing.Settings}
+		known = nil
 		for file := range currentFiles {
 			manifest.FileList[file] = true
 		}
@@ -37,6 +49,14 @@ func (mg *ManifestGenerator) Generate(force bool, manifestFile string) (bool, er
 		manifest = updatedManifest
 	}
 
+	manifest = mg.annotateBinaryFiles(manifest, known)
+
+	allowlist, err := LoadAllowlist(allowlistPathFor(manifestFile))
+	if err != nil {
+		return false, fmt.Errorf("error reading allowlist: %w", err)
+	}
+	manifest = mg.commentSensitiveFiles(manifest, allowlist, known)
+
 	if err := mg.writer.WriteManifest(manifest, manifestFile); err != nil {
 		return false, fmt.Errorf("error writing manifest: %w", err)
 	}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"testing/fstest"
 
@@ -13,7 +14,7 @@ func TestGenerateWithNilFS(t *testing.T) {
 	logger := zapr.NewLogger(zaptest.NewLogger(t))
 	mg := NewManifestGenerator(logger)
 
-	_, err := mg.Generate(false, ".nearwait.yml")
+	_, err := mg.Generate(false, filepath.Join(t.TempDir(), ".nearwait.yml"))
 	if err == nil {
 		t.Error("Generate() with nil FS should return error")
 	}
@@ -28,7 +29,7 @@ func TestGenerateWithFS(t *testing.T) {
 	}
 	mg.WithFS(fsys)
 
-	_, err := mg.Generate(false, ".nearwait.yml")
+	_, err := mg.Generate(false, filepath.Join(t.TempDir(), ".nearwait.yml"))
 	if err != nil {
 		t.Errorf("Generate() with valid FS failed: %v", err)
 	}
@@ -39,7 +40,7 @@ func TestGenerateWithRealFS(t *testing.T) {
 	mg := NewManifestGenerator(logger)
 	mg.WithFS(os.DirFS("."))
 
-	_, err := mg.Generate(false, ".nearwait.yml")
+	_, err := mg.Generate(false, filepath.Join(t.TempDir(), ".nearwait.yml"))
 	if err != nil {
 		t.Errorf("Generate() with real FS failed: %v", err)
 	}
@@ -2,12 +2,19 @@ package core
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"strings"
 )
 
 func (mg *ManifestGenerator) ReadManifest(manifestFile string) (Manifest, error) {
-	manifest := Manifest{FileList: make(map[string]bool)}
+	manifest := Manifest{
+		FileList: make(map[string]bool),
+		Notes:    make(map[string]string),
+		Ranges:   make(map[string][]LineRange),
+		Symbols:  make(map[string][]string),
+		Settings: make(map[string]string),
+	}
 
 	if _, err := os.Stat(manifestFile); os.IsNotExist(err) {
 		return manifest, nil
@@ -22,20 +29,44 @@ func (mg *ManifestGenerator) ReadManifest(manifestFile string) (Manifest, error)
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
+		var entry string
+		var isCommented bool
 		if strings.HasPrefix(line, "# - ") {
-			path := strings.TrimPrefix(line, "# - ")
-			normalizedPath, err := normalizePathForComparison(path)
-			if err != nil {
-				return manifest, err
-			}
-			manifest.FileList[normalizedPath] = true
+			entry = strings.TrimPrefix(line, "# - ")
+			isCommented = true
 		} else if strings.HasPrefix(line, "- ") {
-			path := strings.TrimPrefix(line, "- ")
-			normalizedPath, err := normalizePathForComparison(path)
-			if err != nil {
-				return manifest, err
-			}
-			manifest.FileList[normalizedPath] = false
+			entry = strings.TrimPrefix(line, "- ")
+		} else if key, value, ok := splitSetting(line); ok {
+			manifest.Settings[key] = value
+			continue
+		} else {
+			continue
+		}
+
+		spec, note := splitNote(entry)
+		spec, symbols := splitSymbols(spec)
+		path, ranges, err := splitRanges(spec)
+		if err != nil {
+			return manifest, err
+		}
+		if len(ranges) > 0 && len(symbols) > 0 && !isCommented {
+			// Line numbers would not match the extracted declarations
+			return manifest, fmt.Errorf("manifest entry %q combines line ranges with symbols; select one or the other", entry)
+		}
+		normalizedPath, err := normalizePathForComparison(mg.dir, path)
+		if err != nil {
+			return manifest, err
+		}
+		manifest.FileList[normalizedPath] = isCommented
+		manifest.Order = append(manifest.Order, normalizedPath)
+		if note != "" {
+			manifest.Notes[normalizedPath] = note
+		}
+		if len(ranges) > 0 {
+			manifest.Ranges[normalizedPath] = ranges
+		}
+		if len(symbols) > 0 {
+			manifest.Symbols[normalizedPath] = symbols
 		}
 	}
 
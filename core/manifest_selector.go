@@ -0,0 +1,62 @@
+package core
+
+import "fmt"
+
+// EnableFiles uncomments files in the manifest, adding entries that are not
+// listed yet, and writes it back through the manifest writer. It returns the
+// number of files that were not already enabled.
+func (mg *ManifestGenerator) EnableFiles(manifestFile string, files []string) (int, error) {
+	selection := make(map[string][]LineRange, len(files))
+	for _, file := range files {
+		selection[file] = nil
+	}
+	return mg.EnableFileRanges(manifestFile, selection)
+}
+
+// EnableFileRanges is EnableFiles with line ranges per file. Ranges are added
+// to the ranges a file already has; nil ranges, or a file that was already
+// enabled in full, select the whole file.
+func (mg *ManifestGenerator) EnableFileRanges(manifestFile string, files map[string][]LineRange) (int, error) {
+	manifest, err := mg.reader.ReadManifest(manifestFile)
+	if err != nil {
+		return 0, fmt.Errorf("error reading manifest: %w", err)
+	}
+	if manifest.FileList == nil {
+		manifest.FileList = make(map[string]bool)
+	}
+	if manifest.Ranges == nil {
+		manifest.Ranges = make(map[string][]LineRange)
+	}
+
+	enabled := 0
+	for file, ranges := range files {
+		normalizedFile, err := normalizePathForComparison(mg.dir, file)
+		if err != nil {
+			return 0, err
+		}
+		isCommented, exists := manifest.FileList[normalizedFile]
+		wasEnabled := exists && !isCommented
+		if !wasEnabled {
+			enabled++
+		}
+
+		if len(ranges) > 0 && len(manifest.Symbols[normalizedFile]) > 0 {
+			return 0, fmt.Errorf("%s selects symbols, which cannot be combined with line ranges", normalizedFile)
+		}
+		switch {
+		case len(ranges) == 0:
+			delete(manifest.Ranges, normalizedFile)
+		case !wasEnabled:
+			manifest.Ranges[normalizedFile] = mergeRanges(ranges)
+		case len(manifest.Ranges[normalizedFile]) > 0:
+			manifest.Ranges[normalizedFile] = mergeRanges(append(manifest.Ranges[normalizedFile], ranges...))
+		}
+		manifest.FileList[normalizedFile] = false
+		mg.logger.V(1).Info("Enabled file", "path", normalizedFile, "ranges", formatRanges(manifest.Ranges[normalizedFile]))
+	}
+
+	if err := mg.writer.WriteManifest(manifest, manifestFile); err != nil {
+		return 0, fmt.Errorf("error writing manifest: %w", err)
+	}
+	return enabled, nil
+}
@@ -1,20 +1,52 @@
 package core
 
+import "path/filepath"
+
 func (mg *ManifestGenerator) UpdateManifest(manifest Manifest, currentFiles map[string]bool) Manifest {
-	updatedManifest := Manifest{FileList: make(map[string]bool)}
+	updatedManifest := Manifest{
+		FileList: make(map[string]bool),
+		Notes:    make(map[string]string),
+		Ranges:   make(map[string][]LineRange),
+		Symbols:  make(map[string][]string),
+		Settings: manifest.Settings,
+	}
 
 	for file := range currentFiles {
-		normalizedFile, err := normalizePathForComparison(file)
+		normalizedFile, err := normalizePathForComparison(mg.dir, file)
 		if err != nil {
 			mg.logger.Error(err, "Failed to normalize path", "path", file)
 			continue
 		}
 		if isCommented, exists := manifest.FileList[normalizedFile]; exists {
 			updatedManifest.FileList[normalizedFile] = isCommented
+			if note, ok := manifest.Notes[normalizedFile]; ok {
+				updatedManifest.Notes[normalizedFile] = note
+			}
+			if ranges, ok := manifest.Ranges[normalizedFile]; ok {
+				updatedManifest.Ranges[normalizedFile] = ranges
+			}
+			if symbols, ok := manifest.Symbols[normalizedFile]; ok {
+				updatedManifest.Symbols[normalizedFile] = symbols
+			}
 		} else {
 			updatedManifest.FileList[normalizedFile] = true
 		}
 	}
 
+	// Package entries such as "core#ClipboardWriter" name a directory rather
+	// than a file, so keep them as long as the package still has files
+	for dir, symbols := range manifest.Symbols {
+		if _, exists := updatedManifest.FileList[dir]; exists {
+			continue
+		}
+		for file := range updatedManifest.FileList {
+			if filepath.Dir(file) == dir {
+				updatedManifest.FileList[dir] = manifest.FileList[dir]
+				updatedManifest.Symbols[dir] = symbols
+				break
+			}
+		}
+	}
+
 	return updatedManifest
 }
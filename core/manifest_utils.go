@@ -1,30 +1,30 @@
 package core
 
 import (
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/mitchellh/go-homedir"
 )
 
-func normalizePathForComparison(path string) (string, error) {
+// normalizePathForComparison makes path relative to the project directory
+// root, so absolute and relative spellings of an entry compare equal
+func normalizePathForComparison(root, path string) (string, error) {
 	expandedPath, err := homedir.Expand(path)
 	if err != nil {
 		return "", err
 	}
 
-	absPath, err := filepath.Abs(expandedPath)
+	base, err := filepath.Abs(root)
 	if err != nil {
 		return "", err
 	}
-
-	cwd, err := os.Getwd()
-	if err != nil {
-		return "", err
+	absPath := expandedPath
+	if !filepath.IsAbs(absPath) {
+		absPath = filepath.Join(base, absPath)
 	}
 
-	relPath, err := filepath.Rel(cwd, absPath)
+	relPath, err := filepath.Rel(base, absPath)
 	if err != nil {
 		return "", err
 	}
@@ -34,3 +34,28 @@ func normalizePathForComparison(path string) (string, error) {
 
 	return cleanPath, nil
 }
+
+// noteSeparator separates a manifest entry from its trailing note
+const noteSeparator = "  # "
+
+// splitNote splits a manifest entry such as "logo.png  # binary" into its path and note
+func splitNote(entry string) (string, string) {
+	if i := strings.Index(entry, " # "); i >= 0 {
+		return strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+3:])
+	}
+	return entry, ""
+}
+
+// splitSetting parses a top-level manifest line such as "pair_tests: true"
+func splitSetting(line string) (string, string, bool) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok || key == "filelist" || key == "" {
+		return "", "", false
+	}
+	for _, r := range key {
+		if r != '_' && (r < 'a' || r > 'z') {
+			return "", "", false
+		}
+	}
+	return key, strings.TrimSpace(value), true
+}
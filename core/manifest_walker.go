@@ -29,7 +29,7 @@ func (mg *ManifestGenerator) GetCurrentFiles() (map[string]bool, error) {
 				return nil
 			}
 
-			if !mg.isExcluded(path) {
+			if !mg.isExcluded(path) && mg.isListed(path, d) {
 				files[path] = true
 			}
 			return nil
@@ -62,7 +62,7 @@ func (mg *ManifestGenerator) GetCurrentFiles() (map[string]bool, error) {
 				return nil
 			}
 
-			if !mg.isExcluded(path) {
+			if !mg.isExcluded(path) && mg.isListed(path, d) {
 				files[path] = true
 			}
 			return nil
@@ -73,3 +73,13 @@ func (mg *ManifestGenerator) GetCurrentFiles() (map[string]bool, error) {
 	}
 	return files, nil
 }
+
+// isListed reports whether the walked entry is offered in the manifest:
+// symlinks are only when they resolve to a file inside the file system
+func (mg *ManifestGenerator) isListed(path string, d fs.DirEntry) bool {
+	if d.Type()&fs.ModeSymlink == 0 {
+		return true
+	}
+	info, err := fs.Stat(mg.fsys, path)
+	return err == nil && info.Mode().IsRegular()
+}
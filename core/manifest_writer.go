@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 )
 
 func (mg *ManifestGenerator) WriteManifest(manifest Manifest, manifestFile string) error {
@@ -15,6 +16,17 @@ func (mg *ManifestGenerator) WriteManifest(manifest Manifest, manifestFile strin
 	defer file.Close()
 
 	writer := bufio.NewWriter(file)
+	var settings []string
+	for key := range manifest.Settings {
+		settings = append(settings, key)
+	}
+	sort.Strings(settings)
+	for _, key := range settings {
+		if _, err := fmt.Fprintf(writer, "%s: %s\n", key, manifest.Settings[key]); err != nil {
+			return err
+		}
+	}
+
 	_, err = writer.WriteString("filelist:\n")
 	if err != nil {
 		return err
@@ -32,7 +44,17 @@ func (mg *ManifestGenerator) WriteManifest(manifest Manifest, manifestFile strin
 		if isCommented {
 			prefix = "# - "
 		}
-		_, err = writer.WriteString(fmt.Sprintf("%s%s\n", prefix, file))
+		line := prefix + file
+		if ranges := manifest.Ranges[file]; len(ranges) > 0 {
+			line += ":" + formatRanges(ranges)
+		}
+		if symbols := manifest.Symbols[file]; len(symbols) > 0 {
+			line += "#" + strings.Join(symbols, ",")
+		}
+		if note := manifest.Notes[file]; note != "" {
+			line += noteSeparator + note
+		}
+		_, err = writer.WriteString(fmt.Sprintf("%s\n", line))
 		if err != nil {
 			return err
 		}
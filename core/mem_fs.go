@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// memFS is a read-only file system held in memory, built from the entries of
+// an archive. Directories are implied by the paths of the files below them.
+type memFS struct {
+	files map[string]*memFile
+	// dirs maps every directory to the sorted names of its children
+	dirs map[string][]string
+}
+
+type memFile struct {
+	data    []byte
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	// err is returned when opening a file whose contents were not loaded
+	err error
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: make(map[string]*memFile), dirs: map[string][]string{".": nil}}
+}
+
+// cleanName checks the slash-separated name of an archive entry, rejecting
+// names that would escape the root such as "../x" or "/etc/passwd"
+func cleanName(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if !fs.ValidPath(clean) || clean == "." {
+		return "", fmt.Errorf("invalid path %q in archive", name)
+	}
+	return clean, nil
+}
+
+// add stores a regular file under the slash-separated name
+func (m *memFS) add(name string, data []byte, mode fs.FileMode, modTime time.Time) error {
+	return m.put(name, &memFile{data: data, size: int64(len(data)), mode: mode.Perm(), modTime: modTime})
+}
+
+// put stores file under the slash-separated name; several names may share
+// a file
+func (m *memFS) put(name string, file *memFile) error {
+	clean, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	m.files[clean] = file
+
+	// Index the file in its directory and each directory in its parent,
+	// stopping at the first one that is already known
+	for child := clean; child != "."; child = path.Dir(child) {
+		dir, name := path.Dir(child), path.Base(child)
+		i, found := slices.BinarySearch(m.dirs[dir], name)
+		if found {
+			break
+		}
+		m.dirs[dir] = slices.Insert(m.dirs[dir], i, name)
+	}
+	return nil
+}
+
+func (f *memFile) info(name string) fs.FileInfo {
+	return &memFileInfo{name: name, size: f.size, mode: f.mode, modTime: f.modTime}
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if file, ok := m.files[name]; ok {
+		if file.err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: file.err}
+		}
+		return &openMemFile{Reader: bytes.NewReader(file.data), info: file.info(path.Base(name))}, nil
+	}
+
+	names, ok := m.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, child := range names {
+		var info fs.FileInfo = &memFileInfo{name: child, mode: fs.ModeDir | 0o755}
+		if file, ok := m.files[path.Join(name, child)]; ok {
+			info = file.info(child)
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return &openMemDir{info: &memFileInfo{name: path.Base(name), mode: fs.ModeDir | 0o755}, entries: entries}, nil
+}
+
+// Stat describes files whose contents were not loaded as well
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if file, ok := m.files[name]; ok {
+		return file.info(path.Base(name)), nil
+	}
+	if _, ok := m.dirs[name]; ok {
+		return &memFileInfo{name: path.Base(name), mode: fs.ModeDir | 0o755}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+type openMemFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *openMemFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openMemFile) Close() error               { return nil }
+
+type openMemDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openMemDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openMemDir) Close() error               { return nil }
+
+func (d *openMemDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (d *openMemDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return nil }
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMemFS(t *testing.T) {
+	fsys := newMemFS()
+	for _, name := range []string{"b/z.go", "a.txt", "b/c/d.go", "./b/y.go", "b/z.go"} {
+		if err := fsys.add(name, []byte(name), 0o644, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fstest.TestFS(fsys, "a.txt", "b/y.go", "b/z.go", "b/c/d.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := fs.ReadDir(fsys, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"c", "y.go", "z.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ReadDir(b) = %v, want %v", names, want)
+	}
+	if _, err := fsys.Open("b/x"); err == nil {
+		t.Error("Open(b/x) expected an error")
+	}
+}
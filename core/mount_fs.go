@@ -0,0 +1,243 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// mountsSetting declares directories outside the project root, e.g.
+// "mounts: {shared: ../shared-lib}" makes ../shared-lib/lib.go available as
+// shared/lib.go
+const mountsSetting = "mounts"
+
+// ParseMounts parses the value of the mounts setting, a flow mapping such as
+// "{shared: ../shared-lib, proto: ~/src/proto}", into mount names and
+// directories
+func ParseMounts(value string) (map[string]string, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "{"), "}")
+
+	mounts := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, dir, ok := strings.Cut(pair, ":")
+		name, dir = strings.TrimSpace(name), strings.TrimSpace(dir)
+		if !ok || dir == "" {
+			return nil, fmt.Errorf("invalid mount %q: want name: directory", strings.TrimSpace(pair))
+		}
+		if !fs.ValidPath(name) || name == "." || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid mount name %q: want a single path element", name)
+		}
+		if _, ok := mounts[name]; ok {
+			return nil, fmt.Errorf("mount %s is declared twice", name)
+		}
+		mounts[name] = dir
+	}
+	return mounts, nil
+}
+
+// mountSettings opens the directories declared by the mounts setting,
+// resolving relative directories against the project directory; no setting
+// means no mounts. The opened directories are returned to be closed by the
+// caller, even along with an error.
+func mountSettings(settings map[string]string, projectDir string) (map[string]fs.FS, dirRoots, error) {
+	value, ok := settings[mountsSetting]
+	if !ok {
+		return nil, nil, nil
+	}
+	mounts, err := ParseMounts(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opened := make(map[string]fs.FS, len(mounts))
+	var roots dirRoots
+	for name, dir := range mounts {
+		expanded, err := homedir.Expand(dir)
+		if err != nil {
+			return nil, roots, fmt.Errorf("mount %s: %w", name, err)
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(projectDir, expanded)
+		}
+		info, err := os.Stat(expanded)
+		if err != nil {
+			return nil, roots, fmt.Errorf("mount %s: %w", name, err)
+		}
+		if !info.IsDir() {
+			return nil, roots, fmt.Errorf("mount %s: %s is not a directory", name, dir)
+		}
+		fsys, root, err := OpenDirFS(expanded)
+		if err != nil {
+			return nil, roots, fmt.Errorf("mount %s: %w", name, err)
+		}
+		opened[name] = fsys
+		roots = append(roots, root)
+	}
+	return opened, roots, nil
+}
+
+// mountFS serves a project root with extra directories mounted under
+// top-level names. Like any fs.FS it only accepts paths that stay below its
+// root, so nothing outside the project and its mounts can be reached.
+type mountFS struct {
+	base   fs.FS
+	mounts map[string]fs.FS
+}
+
+// newMountFS mounts each of mounts under its name, refusing names that would
+// hide a file or directory of the project
+func newMountFS(base fs.FS, mounts map[string]fs.FS) (fs.FS, error) {
+	if len(mounts) == 0 {
+		return base, nil
+	}
+	for name := range mounts {
+		if _, err := fs.Stat(base, name); err == nil {
+			return nil, fmt.Errorf("mount %s would hide %s in the project", name, name)
+		}
+	}
+	return &mountFS{base: base, mounts: mounts}, nil
+}
+
+// resolve finds the file system serving name and the path within it
+func (m *mountFS) resolve(op, name string) (fs.FS, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	top, rest, _ := strings.Cut(name, "/")
+	if mount, ok := m.mounts[top]; ok {
+		if rest == "" {
+			rest = "."
+		}
+		return mount, rest, nil
+	}
+	return m.base, name, nil
+}
+
+func (m *mountFS) Open(name string) (fs.File, error) {
+	if name == "." {
+		info, err := fs.Stat(m.base, ".")
+		if err != nil {
+			return nil, err
+		}
+		entries, err := m.ReadDir(".")
+		if err != nil {
+			return nil, err
+		}
+		return &openMemDir{info: info, entries: entries}, nil
+	}
+	fsys, rel, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := fsys.Open(rel)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: unwrapPathError(err)}
+	}
+	if _, ok := m.mounts[name]; ok {
+		// The root of a mount reports itself as "."
+		dir, ok := file.(fs.ReadDirFile)
+		if !ok {
+			file.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+		}
+		info, err := m.Stat(name)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &mountRoot{ReadDirFile: dir, info: info}, nil
+	}
+	return file, nil
+}
+
+func (m *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		fsys, rel, err := m.resolve("readdir", name)
+		if err != nil {
+			return nil, err
+		}
+		return fs.ReadDir(fsys, rel)
+	}
+
+	entries, err := fs.ReadDir(m.base, ".")
+	if err != nil {
+		return nil, err
+	}
+	for mount := range m.mounts {
+		info, err := m.Stat(mount)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *mountFS) Stat(name string) (fs.FileInfo, error) {
+	if mount, ok := m.mounts[name]; ok {
+		info, err := fs.Stat(mount, ".")
+		if err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: unwrapPathError(err)}
+		}
+		return renamedInfo{FileInfo: info, name: name}, nil
+	}
+	fsys, rel, err := m.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(fsys, rel)
+}
+
+// Lstat and ReadLink let callers tell symlinks apart, such as links the
+// project or a mount refuses to follow
+func (m *mountFS) Lstat(name string) (fs.FileInfo, error) {
+	if _, ok := m.mounts[name]; ok {
+		return m.Stat(name)
+	}
+	fsys, rel, err := m.resolve("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Lstat(fsys, rel)
+}
+
+func (m *mountFS) ReadLink(name string) (string, error) {
+	fsys, rel, err := m.resolve("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return fs.ReadLink(fsys, rel)
+}
+
+// mountRoot is the opened root directory of a mount
+type mountRoot struct {
+	fs.ReadDirFile
+	info fs.FileInfo
+}
+
+func (r *mountRoot) Stat() (fs.FileInfo, error) { return r.info, nil }
+
+// renamedInfo reports a directory under the name it is mounted as
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string { return i.name }
+
+func unwrapPathError(err error) error {
+	if pathErr, ok := err.(*fs.PathError); ok {
+		return pathErr.Err
+	}
+	return err
+}
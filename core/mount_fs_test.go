@@ -0,0 +1,239 @@
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseMounts(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr string
+	}{
+		{value: "{shared: ../shared-lib}", want: map[string]string{"shared": "../shared-lib"}},
+		{value: "{shared: ../shared-lib, proto: ~/src/proto}", want: map[string]string{"shared": "../shared-lib", "proto": "~/src/proto"}},
+		{value: "{}", want: map[string]string{}},
+		{value: "{shared}", wantErr: "invalid mount"},
+		{value: "{a/b: ../x}", wantErr: "invalid mount name"},
+		{value: "{..: ../x}", wantErr: "invalid mount name"},
+		{value: "{x: ../a, x: ../b}", wantErr: "declared twice"},
+	}
+	for _, tt := range tests {
+		got, err := ParseMounts(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseMounts(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMounts(%q) error = %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMounts(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMountFS(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"proj/go.mod":          "module example.com/proj\n",
+		"proj/core/a.go":       "package core\n",
+		"shared-lib/lib.go":    "package shared\n",
+		"shared-lib/util/u.go": "package util\n",
+		"secret.txt":           "outside every root\n",
+	})
+	proj := filepath.Join(root, "proj")
+
+	mounts, roots, err := mountSettings(map[string]string{mountsSetting: "{shared: ../shared-lib}"}, proj)
+	if err != nil {
+		t.Fatalf("mountSettings() error = %v", err)
+	}
+	defer roots.Close()
+	fsys, err := newMountFS(os.DirFS(proj), mounts)
+	if err != nil {
+		t.Fatalf("newMountFS() error = %v", err)
+	}
+
+	if err := fstest.TestFS(fsys, "go.mod", "core/a.go", "shared/lib.go", "shared/util/u.go"); err != nil {
+		t.Errorf("TestFS() error = %v", err)
+	}
+	if data, err := fs.ReadFile(fsys, "shared/util/u.go"); err != nil || string(data) != "package util\n" {
+		t.Errorf("ReadFile(shared/util/u.go) = %q, %v", data, err)
+	}
+	for _, name := range []string{"../secret.txt", "shared/../../secret.txt", "/secret.txt"} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%s) error = %v, want fs.ErrInvalid", name, err)
+		}
+	}
+
+	if _, err := newMountFS(os.DirFS(proj), map[string]fs.FS{"core": os.DirFS(root)}); err == nil {
+		t.Error("newMountFS() hiding a project directory succeeded, want an error")
+	}
+}
+
+func TestGeneratorMounts(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"proj/main.go":      "package main\n",
+		"shared-lib/lib.go": "package shared\n",
+	})
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	manifest := "mounts: {shared: " + filepath.Join(root, "shared-lib") + "}\nfilelist:\n"
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	generator := NewManifestGenerator(testLogger(t)).WithFS(os.DirFS(filepath.Join(root, "proj")))
+	if _, err := generator.Generate(false, manifestFile); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	got, err := generator.ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"main.go": true, filepath.Join("shared", "lib.go"): true}
+	if !reflect.DeepEqual(got.FileList, want) {
+		t.Errorf("FileList = %v, want %v", got.FileList, want)
+	}
+	if got.Settings[mountsSetting] == "" {
+		t.Error("Generate() dropped the mounts setting")
+	}
+}
+
+func TestMountsClosed(t *testing.T) {
+	if _, err := os.ReadDir("/proc/self/fd"); err != nil {
+		t.Skipf("cannot count open files: %v", err)
+	}
+	openFiles := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+
+	root := writeTestModule(t, map[string]string{
+		"proj/main.go":      "package main\n",
+		"shared-lib/lib.go": "package shared\n",
+	})
+	proj := filepath.Join(root, "proj")
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	manifest := "mounts: {shared: ../shared-lib}\nfilelist:\n- main.go\n- shared/lib.go\n"
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	generator := NewManifestGenerator(testLogger(t)).WithFS(os.DirFS(proj)).WithDir(proj)
+	processor := NewManifestProcessor(testLogger(t), false, manifestFile).
+		WithDir(proj).
+		WithOutput(NewMemorySink()).
+		WithNoopClipboard()
+
+	before := openFiles()
+	for range 5 {
+		if _, err := generator.Generate(false, manifestFile); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if _, err := processor.Build(t.Context()); err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+	}
+	if after := openFiles(); after > before {
+		t.Errorf("open files grew from %d to %d over five runs", before, after)
+	}
+}
+
+func TestSymlinksOutsideRoots(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"proj/main.go":      "package main\n",
+		"shared-lib/lib.go": "package shared\n",
+		"secret.txt":        "outside every root\n",
+	})
+	proj := filepath.Join(root, "proj")
+	links := map[string]string{
+		filepath.Join(proj, "link.txt"):               filepath.Join(root, "secret.txt"),
+		filepath.Join(proj, "inner.txt"):              "main.go",
+		filepath.Join(root, "shared-lib", "leak.txt"): filepath.Join(root, "secret.txt"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	manifest := "mounts: {shared: " + filepath.Join(root, "shared-lib") + "}\nfilelist:\n"
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	projectFS, projectRoot, err := OpenDirFS(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer projectRoot.Close()
+
+	generator := NewManifestGenerator(testLogger(t)).WithFS(projectFS)
+	if _, err := generator.Generate(false, manifestFile); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	got, err := generator.ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"main.go": true, "inner.txt": true, filepath.Join("shared", "lib.go"): true}
+	if !reflect.DeepEqual(got.FileList, want) {
+		t.Errorf("FileList = %v, want %v", got.FileList, want)
+	}
+
+	manifest += "- main.go\n- inner.txt\n- link.txt\n- shared/leak.txt\n"
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report := &Report{}
+	processor := NewManifestProcessor(testLogger(t), false, manifestFile).
+		WithFS(projectFS).
+		WithOutput(NewMemorySink()).
+		WithNoopClipboard().
+		WithReport(report)
+	bundle, err := processor.Build(t.Context())
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if strings.Contains(string(bundle.Archive), "outside every root") {
+		t.Errorf("archive holds the target of a symlink outside the root:\n%s", bundle.Archive)
+	}
+	statuses := make(map[string]string)
+	for _, f := range report.Files {
+		statuses[f.Path] = f.Status
+	}
+	wantStatuses := map[string]string{"main.go": FileIncluded, "inner.txt": FileIncluded, "link.txt": FileSkipped, "shared/leak.txt": FileSkipped}
+	if !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("file statuses = %v, want %v", statuses, wantStatuses)
+	}
+}
+
+func TestCheckEntryPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileList map[string]bool
+		wantErr  bool
+	}{
+		{name: "project and mounted files", fileList: map[string]bool{"main.go": false, "shared/lib.go": false}},
+		{name: "commented entry outside the root", fileList: map[string]bool{"../../shared/lib.go": true}},
+		{name: "enabled entry outside the root", fileList: map[string]bool{"../../shared/lib.go": false}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkEntryPaths(Manifest{FileList: tt.fileList})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkEntryPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
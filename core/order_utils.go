@@ -0,0 +1,228 @@
+package core
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+)
+
+// OrderStrategy controls the order of files in the txtar archive
+type OrderStrategy string
+
+const (
+	// OrderDefault keeps the historical behaviour: lexical for a single
+	// archive, largest first for batches
+	OrderDefault OrderStrategy = ""
+	// OrderLexical sorts files by path
+	OrderLexical OrderStrategy = "lexical"
+	// OrderManifest keeps the order of the entries in the manifest
+	OrderManifest OrderStrategy = "manifest"
+	// OrderSize puts the largest files first
+	OrderSize OrderStrategy = "size"
+	// OrderDeps sorts Go packages so every package comes after the packages
+	// it imports, with main packages last and tests after their sources
+	OrderDeps OrderStrategy = "deps"
+)
+
+func ParseOrderStrategy(s string) (OrderStrategy, error) {
+	switch strategy := OrderStrategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case OrderDefault, OrderLexical, OrderManifest, OrderSize, OrderDeps:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown order %q (want deps, manifest, lexical or size)", s)
+}
+
+// WithOrder sets the order of files in single and batched archives
+func (mp *ManifestProcessor) WithOrder(order OrderStrategy) *ManifestProcessor {
+	mp.order = order
+	return mp
+}
+
+// renderedFile pairs a rendered section with the file it came from
+type renderedFile struct {
+	path    string
+	content []byte
+	section txtar.File
+}
+
+// orderFiles sorts files according to the configured strategy
+func (mp *ManifestProcessor) orderFiles(files []renderedFile) {
+	byPath := func(i, j int) bool { return files[i].path < files[j].path }
+
+	switch mp.order {
+	case OrderManifest:
+		position := make(map[string]int, len(mp.manifest.Order))
+		for i, entry := range mp.manifest.Order {
+			position[entry] = i
+		}
+		rank := func(path string) int {
+			if i, ok := position[path]; ok {
+				return i
+			}
+			// Files pulled in through a package entry follow that entry
+			if i, ok := position[filepath.Dir(path)]; ok {
+				return i
+			}
+			return len(position)
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			ri, rj := rank(files[i].path), rank(files[j].path)
+			if ri != rj {
+				return ri < rj
+			}
+			return byPath(i, j)
+		})
+	case OrderSize:
+		sort.SliceStable(files, func(i, j int) bool {
+			return len(files[i].section.Data) > len(files[j].section.Data)
+		})
+	case OrderDeps:
+		rank := mp.goDependencyRanks(files)
+		sort.SliceStable(files, func(i, j int) bool {
+			ri, rj := rank[files[i].path], rank[files[j].path]
+			if ri != rj {
+				return ri < rj
+			}
+			ti := strings.HasSuffix(files[i].path, "_test.go")
+			tj := strings.HasSuffix(files[j].path, "_test.go")
+			if ti != tj {
+				return tj
+			}
+			return byPath(i, j)
+		})
+	default:
+		sort.SliceStable(files, byPath)
+	}
+}
+
+// goDependencyRanks assigns every file the position of its package in a
+// topological order of the selected Go packages. Non-Go files rank first and
+// main packages rank last. Each revision placed under a prefix is ordered on
+// its own, after the revisions before it.
+func (mp *ManifestProcessor) goDependencyRanks(files []renderedFile) map[string]int {
+	module, moduleErr := mp.goModule()
+
+	groups := make(map[string][]renderedFile)
+	for _, f := range files {
+		prefix := strings.TrimSuffix(f.path, mp.entryPath(f.path))
+		groups[prefix] = append(groups[prefix], f)
+	}
+
+	ranks := make(map[string]int, len(files))
+	offset := 0
+	for _, prefix := range sortedKeys(groups) {
+		order := goPackageOrder(groups[prefix], mp.entryPath, module, moduleErr)
+		for _, f := range groups[prefix] {
+			ranks[f.path] = offset
+			if filepath.Ext(f.path) == ".go" {
+				ranks[f.path] += order[filepath.Dir(mp.entryPath(f.path))]
+			}
+		}
+		offset += len(order) + 1
+	}
+	return ranks
+}
+
+// goModule reads go.mod from the first source, which holds the project or
+// its first revision
+func (mp *ManifestProcessor) goModule() (GoModule, error) {
+	if len(mp.sources) > 0 {
+		return readGoModuleFS(mp.sources[0].fsys, ".")
+	}
+	return ReadGoModule(mp.dir)
+}
+
+// goPackageOrder numbers the package directories of files, as returned by
+// entryPath, in a topological order starting at 1
+func goPackageOrder(files []renderedFile, entryPath func(string) string, module GoModule, moduleErr error) map[string]int {
+	dirs := make(map[string]bool)
+	mainDirs := make(map[string]bool)
+	importPaths := make(map[string]map[string]bool)
+	fset := token.NewFileSet()
+	for _, f := range files {
+		if filepath.Ext(f.path) != ".go" {
+			continue
+		}
+		dir := filepath.Dir(entryPath(f.path))
+		dirs[dir] = true
+		parsed, err := parser.ParseFile(fset, f.path, f.content, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		if parsed.Name.Name == "main" {
+			mainDirs[dir] = true
+		}
+		if importPaths[dir] == nil {
+			importPaths[dir] = make(map[string]bool)
+		}
+		for _, spec := range parsed.Imports {
+			if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+				importPaths[dir][importPath] = true
+			}
+		}
+	}
+
+	// resolve maps an import path to a selected package directory, using
+	// go.mod when available and falling back to matching path suffixes
+	resolve := func(importPath string) (string, bool) {
+		if moduleErr == nil {
+			dir, ok := module.packageDir(importPath)
+			return dir, ok && dirs[dir]
+		}
+		for dir := range dirs {
+			if dir != "." && strings.HasSuffix(importPath, "/"+filepath.ToSlash(dir)) {
+				return dir, true
+			}
+		}
+		return "", false
+	}
+
+	deps := make(map[string][]string)
+	dependents := make(map[string]int)
+	for dir := range dirs {
+		for importPath := range importPaths[dir] {
+			if dep, ok := resolve(importPath); ok && dep != dir {
+				deps[dir] = append(deps[dir], dep)
+				dependents[dir]++
+			}
+		}
+	}
+
+	// Kahn's algorithm, taking ready packages in lexical order with main
+	// packages held back until nothing else is ready
+	remaining := sortedKeys(dirs)
+	order := make(map[string]int)
+	for len(remaining) > 0 {
+		pick := -1
+		for i, dir := range remaining {
+			if dependents[dir] > 0 {
+				continue
+			}
+			if pick < 0 || (mainDirs[remaining[pick]] && !mainDirs[dir]) {
+				pick = i
+			}
+		}
+		if pick < 0 {
+			// Import cycles cannot compile, but keep going in lexical order
+			pick = 0
+		}
+		dir := remaining[pick]
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+		order[dir] = len(order) + 1
+		for _, other := range remaining {
+			for _, dep := range deps[other] {
+				if dep == dir {
+					dependents[other]--
+				}
+			}
+		}
+	}
+
+	return order
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/tools/txtar"
+)
+
+func TestOrderFiles(t *testing.T) {
+	sources := map[string]string{
+		"README.md":        "# demo\n",
+		"cmd/main.go":      "package main\n\nimport \"example.com/demo/core\"\n\nfunc main() { core.Run() }\n",
+		"core/run.go":      "package core\n\nimport \"example.com/demo/util\"\n\nfunc Run() { util.Help() }\n",
+		"core/run_test.go": "package core\n",
+		"util/help.go":     "package util\n\nfunc Help() {}\n",
+		"util/big.go":      "package util\n\n// padding to make this the largest file in the set\nvar Big = 1\n",
+	}
+
+	paths := make([]string, 0, len(sources))
+	for path := range sources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	tests := []struct {
+		name     string
+		order    OrderStrategy
+		manifest []string
+		want     []string
+	}{
+		{
+			name:  "lexical",
+			order: OrderLexical,
+			want:  []string{"README.md", "cmd/main.go", "core/run.go", "core/run_test.go", "util/big.go", "util/help.go"},
+		},
+		{
+			name:  "deps",
+			order: OrderDeps,
+			want:  []string{"README.md", "util/big.go", "util/help.go", "core/run.go", "core/run_test.go", "cmd/main.go"},
+		},
+		{
+			name:     "manifest",
+			order:    OrderManifest,
+			manifest: []string{"util", "core/run_test.go", "cmd/main.go", "core/run.go", "README.md"},
+			want:     []string{"util/big.go", "util/help.go", "core/run_test.go", "cmd/main.go", "core/run.go", "README.md"},
+		},
+		{
+			name:  "size",
+			order: OrderSize,
+			want:  []string{"util/big.go", "cmd/main.go", "core/run.go", "util/help.go", "core/run_test.go", "README.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml").WithOrder(tt.order)
+			mp.manifest = Manifest{Order: tt.manifest}
+
+			var files []renderedFile
+			for _, path := range paths {
+				content := []byte(sources[path])
+				files = append(files, renderedFile{
+					path:    path,
+					content: content,
+					section: txtar.File{Name: path, Data: content},
+				})
+			}
+			mp.orderFiles(files)
+
+			var got []string
+			for _, f := range files {
+				got = append(got, f.path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderFilesRevisionPrefix(t *testing.T) {
+	sources := map[string]string{
+		"cmd/main.go":  "package main\n\nimport \"example.com/demo/core\"\n\nfunc main() { core.Run() }\n",
+		"core/run.go":  "package core\n\nimport \"example.com/demo/util\"\n\nfunc Run() { util.Help() }\n",
+		"util/help.go": "package util\n\nfunc Help() {}\n",
+	}
+	project := fstest.MapFS{"go.mod": {Data: []byte("module example.com/demo\n")}}
+
+	mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml").WithOrder(OrderDeps)
+	mp.sources = []fileSource{{prefix: "v0.1.0", fsys: project}, {prefix: "v0.2.0", fsys: project}}
+
+	var files []renderedFile
+	for _, rev := range []string{"v0.2.0", "v0.1.0"} {
+		for path, content := range sources {
+			path = filepath.Join(rev, filepath.FromSlash(path))
+			files = append(files, renderedFile{
+				path:    path,
+				content: []byte(content),
+				section: txtar.File{Name: filepath.ToSlash(path), Data: []byte(content)},
+			})
+		}
+	}
+	mp.orderFiles(files)
+
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.ToSlash(f.path))
+	}
+	want := []string{
+		"v0.1.0/util/help.go", "v0.1.0/core/run.go", "v0.1.0/cmd/main.go",
+		"v0.2.0/util/help.go", "v0.2.0/core/run.go", "v0.2.0/cmd/main.go",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orderFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestParseOrderStrategy(t *testing.T) {
+	if got, err := ParseOrderStrategy("Deps"); err != nil || got != OrderDeps {
+		t.Errorf("ParseOrderStrategy(Deps) = %q, %v", got, err)
+	}
+	if _, err := ParseOrderStrategy("random"); err == nil {
+		t.Error("ParseOrderStrategy(random) expected an error")
+	}
+}
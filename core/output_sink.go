@@ -0,0 +1,93 @@
+package core
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// OutputSink stores the archives a run produces, such as .nearwait.txtar,
+// by name
+type OutputSink interface {
+	WriteFile(name string, data []byte) error
+	ReadFile(name string) ([]byte, error)
+	Remove(name string) error
+}
+
+// DirSink writes archives into a directory on disk
+type DirSink struct {
+	Dir string
+}
+
+func NewDirSink(dir string) *DirSink {
+	return &DirSink{Dir: dir}
+}
+
+func (s *DirSink) WriteFile(name string, data []byte) error {
+	target := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0o644)
+}
+
+func (s *DirSink) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.Dir, name))
+}
+
+func (s *DirSink) Remove(name string) error {
+	err := os.Remove(filepath.Join(s.Dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// MemorySink keeps archives in memory, for tests and callers that want the
+// bundle without touching the disk
+type MemorySink struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: make(map[string][]byte)}
+}
+
+func (s *MemorySink) WriteFile(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *MemorySink) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *MemorySink) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, name)
+	return nil
+}
+
+// Names lists the archives written so far
+func (s *MemorySink) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
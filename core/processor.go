@@ -2,17 +2,23 @@ package core
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/atotto/clipboard"
 	"github.com/go-logr/logr"
+	"golang.org/x/tools/txtar"
 )
 
 type ArchiveProcessor interface {
-	ProcessTarArchive(manifest Manifest, projectInfo ProjectInfo) error
-	ProcessTxtarArchive(manifest Manifest, projectInfo ProjectInfo) error
+	StageFiles(ctx context.Context, manifest Manifest) (fs.FS, error)
+	ProcessTxtarArchive(ctx context.Context, manifest Manifest, projectInfo ProjectInfo) ([]byte, error)
 }
 
 type ClipboardWriter interface {
@@ -20,15 +26,23 @@ type ClipboardWriter interface {
 	ShouldDelay() bool // New method to determine if delays should be applied
 }
 
+// ErrNoClipboard is returned by a ClipboardWriter when the system has no
+// clipboard, as on a headless CI machine. Process then skips the copy rather
+// than failing.
+var ErrNoClipboard = errors.New("no clipboard utilities available")
+
 // SystemClipboard implements ClipboardWriter using the real system clipboard
 type SystemClipboard struct{}
 
 func (c *SystemClipboard) WriteAll(text string) error {
+	if clipboard.Unsupported {
+		return ErrNoClipboard
+	}
 	return clipboard.WriteAll(text)
 }
 
 func (c *SystemClipboard) ShouldDelay() bool {
-	return true // Real clipboard operations should have delays
+	return !clipboard.Unsupported // Real clipboard operations should have delays
 }
 
 // NoopClipboard implements ClipboardWriter with no-op operations
@@ -44,14 +58,47 @@ func (c *NoopClipboard) ShouldDelay() bool {
 }
 
 type ManifestProcessor struct {
-	logger       logr.Logger
-	debug        bool
-	manifestFile string
-	batchKBytes  int64
-	waitBatch    bool
-	reader       ManifestReader
-	archiver     ArchiveProcessor
-	clipboard    ClipboardWriter
+	logger          logr.Logger
+	debug           bool
+	manifestFile    string
+	batchKBytes     int64
+	waitBatch       bool
+	binaryPolicy    BinaryPolicy
+	binaryPolicies  map[string]BinaryPolicy
+	redact          bool
+	allowSecrets    bool
+	redactor        *Redactor
+	outline         bool
+	order           OrderStrategy
+	depAPI          []string
+	pairTests       bool
+	testPatterns    []string
+	pairedTests     []string
+	diffRef         string
+	diffContext     int
+	diffUntracked   bool
+	historyLimit    int
+	historyBody     bool
+	historyMaxBytes int
+	revisions       []string
+	revPrefix       bool
+	dir             string
+	fsys            fs.FS
+	output          OutputSink
+	sources         []fileSource
+	roots           dirRoots
+	extraFiles      []txtar.File
+	virtualFiles    []txtar.File
+	manifest        Manifest
+	reader          ManifestReader
+	archiver        ArchiveProcessor
+	clipboard       ClipboardWriter
+	report          *Report
+	maxTokens       int
+	stagingNotes    []ReportFile
+	rendered        []ReportFile
+	stdin           io.Reader
+	stdout          io.Writer
 }
 
 func NewManifestProcessor(logger logr.Logger, debug bool, manifestFile string) *ManifestProcessor {
@@ -59,9 +106,15 @@ func NewManifestProcessor(logger logr.Logger, debug bool, manifestFile string) *
 		logger:       logger,
 		debug:        debug,
 		manifestFile: manifestFile,
+		dir:          ".",
 		batchKBytes:  0,
 		waitBatch:    false,
+		binaryPolicy: BinarySkip,
+		redact:       true,
 		clipboard:    &SystemClipboard{},
+		stdin:        os.Stdin,
+		stdout:       os.Stdout,
+		output:       NewDirSink(filepath.Dir(txtarPathFor(manifestFile))),
 	}
 	mp.reader = NewManifestGenerator(logger)
 	mp.archiver = mp
@@ -80,125 +133,152 @@ func (mp *ManifestProcessor) WithWaitBatch(waitBatch bool) *ManifestProcessor {
 	return mp
 }
 
+// WithRedaction sets whether secrets are redacted and whether an archive with
+// high-confidence secrets may still be copied to the clipboard
+func (mp *ManifestProcessor) WithRedaction(redact, allowSecrets bool) *ManifestProcessor {
+	mp.redact = redact
+	mp.allowSecrets = allowSecrets
+	return mp
+}
+
+// WithVirtualFile adds a generated file, such as compiler output, to the
+// end of the bundle
+func (mp *ManifestProcessor) WithVirtualFile(name string, data []byte) *ManifestProcessor {
+	mp.extraFiles = append(mp.extraFiles, txtar.File{Name: name, Data: data})
+	return mp
+}
+
+// WithFS reads the project from fsys, such as an archive opened with
+// OpenSourceFS or an fstest.MapFS, instead of the working directory
+func (mp *ManifestProcessor) WithFS(fsys fs.FS) *ManifestProcessor {
+	mp.fsys = fsys
+	return mp
+}
+
+// WithDir sets the project directory, the working directory by default.
+// Manifest entries, mounts, go.mod and the git repository of diffs, history
+// and revisions are all resolved against it.
+func (mp *ManifestProcessor) WithDir(dir string) *ManifestProcessor {
+	mp.dir = dir
+	if generator, ok := mp.reader.(*ManifestGenerator); ok {
+		generator.WithDir(dir)
+	}
+	return mp
+}
+
+// WithOutput sets where the txtar archive is written, next to the manifest
+// by default
+func (mp *ManifestProcessor) WithOutput(output OutputSink) *ManifestProcessor {
+	mp.output = output
+	return mp
+}
+
 // WithClipboard sets a custom clipboard implementation
 func (mp *ManifestProcessor) WithClipboard(clipboard ClipboardWriter) *ManifestProcessor {
 	mp.clipboard = clipboard
 	return mp
 }
 
+// WithConsole sets where Process reports batches and reads the Enter key
+// between them, the terminal by default
+func (mp *ManifestProcessor) WithConsole(in io.Reader, out io.Writer) *ManifestProcessor {
+	mp.stdin = in
+	mp.stdout = out
+	return mp
+}
+
 // WithNoopClipboard sets a no-op clipboard for testing
 func (mp *ManifestProcessor) WithNoopClipboard() *ManifestProcessor {
 	mp.clipboard = &NoopClipboard{}
 	return mp
 }
 
+// Process bundles the enabled files, keeps the debug files when asked and
+// copies the archive or its batches to the clipboard. It reports true when
+// the manifest enables no files.
 func (mp *ManifestProcessor) Process() (bool, error) {
-	mp.logger.V(1).Info("Processing manifest")
-	manifest, err := mp.reader.ReadManifest(mp.manifestFile)
-	if err != nil {
-		return false, err
-	}
-
-	// Check if there are any uncommented entries in the manifest
-	hasUncommentedEntries := false
-	for _, isCommented := range manifest.FileList {
-		if !isCommented {
-			hasUncommentedEntries = true
-			break
-		}
-	}
-
-	if !hasUncommentedEntries {
+	bundle, err := mp.Build(context.Background())
+	if errors.Is(err, ErrEmptyManifest) {
 		return true, nil
 	}
-
-	projectInfo, err := mp.setupProjectInfo()
 	if err != nil {
 		return false, err
 	}
-
-	if !mp.debug {
-		defer os.RemoveAll(projectInfo.TempDir)
-	}
-
-	if err := mp.archiver.ProcessTarArchive(manifest, projectInfo); err != nil {
-		return false, err
-	}
-
-	if err := mp.archiver.ProcessTxtarArchive(manifest, projectInfo); err != nil {
-		return false, err
-	}
-
 	if mp.debug {
-		mp.logger.Info("Debug mode: Temporary directory kept for inspection", "path", projectInfo.TempDir)
+		if err := mp.keepDebugFiles(bundle); err != nil {
+			return false, err
+		}
 	}
 
 	// Process clipboard operations
+	start := time.Now()
+	defer func() { mp.report.timeStage("clipboard", start) }()
 	if mp.batchKBytes <= 0 {
 		// No batching, copy everything at once
-		txtarContent, err := os.ReadFile(projectInfo.TxtarFile)
-		if err != nil {
-			return false, err
-		}
+		return false, mp.copyToClipboard(bundle.Archive, "")
+	}
 
-		if err := mp.clipboard.WriteAll(string(txtarContent)); err != nil {
-			mp.logger.V(1).Info("Skipping clipboard: " + err.Error())
-		} else {
-			mp.logger.V(1).Info("Txtar content copied to clipboard")
-		}
-	} else {
-		// Create batches and copy each batch separately
-		batches, err := mp.createBatches(projectInfo)
-		if err != nil {
-			return false, err
+	batches := bundle.Batches
+	// Output batch count to stdout
+	fmt.Fprintf(mp.stdout, "Created %d batches\n", len(batches))
+	// Delay between batches when not waiting for user input
+	batchDelayMillis := int64(600)
+	reader := bufio.NewReader(mp.stdin)
+	var failed error
+	// Copy all batches to clipboard in sequence, from first to last
+	for i, batchContent := range batches {
+		// If this is not the first batch and waitBatch is enabled, prompt user
+		if i > 0 && mp.waitBatch {
+			fmt.Fprintf(mp.stdout, "Press Enter to copy batch %d/%d...", i+1, len(batches))
+			if _, err := reader.ReadString('\n'); err != nil { // Wait for Enter key
+				mp.logger.V(1).Info("Error reading user input", "error", err.Error())
+			}
 		}
 
-		// Output batch count to stdout
-		fmt.Printf("Created %d batches\n", len(batches))
-		// Delay between batches when not waiting for user input
-		batchDelayMillis := int64(600)
-		// Copy all batches to clipboard in sequence, from first to last
-		for i, batchFile := range batches {
-			batchContent, err := os.ReadFile(batchFile)
-			if err != nil {
-				return false, err
-			}
+		if err := mp.copyToClipboard(batchContent, batchName(i)); err != nil {
+			mp.logger.Info("Failed to copy batch to clipboard", "batch", i+1, "error", err.Error())
+			failed = err
+		}
 
-			// If this is not the first batch and waitBatch is enabled, prompt user
-			if i > 0 && mp.waitBatch {
-				fmt.Printf("Press Enter to copy batch %d/%d...", i+1, len(batches))
-				reader := bufio.NewReader(os.Stdin)
-				_, err = reader.ReadString('\n') // Wait for Enter key
-				if err != nil {
-					mp.logger.V(1).Info("Error reading user input", "error", err.Error())
-				}
+		// Add a delay between clipboard operations if there are multiple batches
+		// (only when waitBatch is disabled, since waitBatch already adds a pause)
+		if i < len(batches)-1 && !mp.waitBatch {
+			// Only apply delays if the clipboard implementation says we should
+			if mp.clipboard.ShouldDelay() {
+				mp.logger.V(1).Info("Delaying before next batch copy",
+					"delay_ms", batchDelayMillis)
+				time.Sleep(time.Duration(batchDelayMillis) * time.Millisecond)
 			}
+		}
+	}
 
-			if err := mp.clipboard.WriteAll(string(batchContent)); err != nil {
-				mp.logger.V(1).Info("Skipping clipboard: " + err.Error())
-			} else {
-				mp.logger.V(1).Info("Batch txtar content copied to clipboard",
-					"batch", i+1,
-					"total_batches", len(batches))
-			}
+	// Log information about all batches
+	mp.logger.V(1).Info("Created and copied batch archives",
+		"count", len(batches))
 
-			// Add a delay between clipboard operations if there are multiple batches
-			// (only when waitBatch is disabled, since waitBatch already adds a pause)
-			if i < len(batches)-1 && !mp.waitBatch {
-				// Only apply delays if the clipboard implementation says we should
-				if mp.clipboard.ShouldDelay() {
-					mp.logger.V(1).Info("Delaying before next batch copy",
-						"delay_ms", batchDelayMillis)
-					time.Sleep(time.Duration(batchDelayMillis) * time.Millisecond)
-				}
-			}
-		}
+	return false, failed
+}
 
-		// Log information about all batches
-		mp.logger.V(1).Info("Created and copied batch files",
-			"count", len(batches),
-			"dir", projectInfo.BatchDir)
+// copyToClipboard copies data to the clipboard and records the result in the
+// report; batch names the batch archive, if any. Having no clipboard at all
+// is not an error.
+func (mp *ManifestProcessor) copyToClipboard(data []byte, batch string) error {
+	result := ClipboardResult{Backend: clipboardBackend(mp.clipboard), Batch: batch, Bytes: len(data)}
+	err := mp.clipboard.WriteAll(string(data))
+	switch {
+	case errors.Is(err, ErrNoClipboard):
+		mp.logger.V(1).Info("Skipping clipboard: "+err.Error(), "batch", batch)
+		result.Status = ClipboardUnavailable
+		err = nil
+	case err != nil:
+		result.Status = ClipboardFailed
+		result.Error = err.Error()
+		err = fmt.Errorf("%w: %v; the archive was written to %s", ErrClipboardFailed, err,
+			describeOutput(mp.output, filepath.Base(txtarPathFor(mp.manifestFile))))
+	default:
+		mp.logger.V(1).Info("Txtar content copied to clipboard", "batch", batch)
+		result.Status = ClipboardCopied
 	}
-
-	return false, nil
+	mp.report.addClipboard(result)
+	return err
 }
@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap/zaptest"
@@ -35,24 +35,6 @@ func TestBatchWait(t *testing.T) {
 	// Create a test logger
 	logger := zapr.NewLogger(zaptest.NewLogger(t))
 
-	// Create temp directories
-	tempDir, err := os.MkdirTemp("", "nearwait_test")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Set up project structure
-	extractDir := filepath.Join(tempDir, "extract")
-	if err := os.MkdirAll(extractDir, 0o755); err != nil {
-		t.Fatalf("Failed to create extract dir: %v", err)
-	}
-
-	batchDir := filepath.Join(tempDir, "batches")
-	if err := os.MkdirAll(batchDir, 0o755); err != nil {
-		t.Fatalf("Failed to create batch dir: %v", err)
-	}
-
 	// Create test files that will be split into batches
 	testFiles := []struct {
 		name    string
@@ -63,49 +45,10 @@ func TestBatchWait(t *testing.T) {
 		{"file3.txt", strings.Repeat("Content for file 3. ", 20)},
 	}
 
-	// Create the files in the extract directory
+	// Create one batch archive per file
+	var batches [][]byte
 	for _, tf := range testFiles {
-		filePath := filepath.Join(extractDir, tf.name)
-		if err := os.WriteFile(filePath, []byte(tf.content), 0o644); err != nil {
-			t.Fatalf("Failed to write test file %s: %v", tf.name, err)
-		}
-	}
-
-	// Create a manifest file
-	manifestFile := filepath.Join(tempDir, ".nearwait.yml")
-	manifestContent := "filelist:\n"
-	for _, tf := range testFiles {
-		manifestContent += "- " + tf.name + "\n"
-	}
-	if err := os.WriteFile(manifestFile, []byte(manifestContent), 0o644); err != nil {
-		t.Fatalf("Failed to write manifest file: %v", err)
-	}
-
-	// Create a txtar file
-	txtarFile := filepath.Join(tempDir, ".nearwait.txtar")
-	txtarContent := ""
-	for _, tf := range testFiles {
-		txtarContent += "-- " + tf.name + " --\n" + tf.content + "\n"
-	}
-	if err := os.WriteFile(txtarFile, []byte(txtarContent), 0o644); err != nil {
-		t.Fatalf("Failed to write txtar file: %v", err)
-	}
-
-	// Create batch files
-	for i, tf := range testFiles {
-		batchFileName := filepath.Join(batchDir, fmt.Sprintf("batch_%03d.txtar", i+1))
-		batchContent := "-- " + tf.name + " --\n" + tf.content + "\n"
-		if err := os.WriteFile(batchFileName, []byte(batchContent), 0o644); err != nil {
-			t.Fatalf("Failed to write batch file %s: %v", batchFileName, err)
-		}
-	}
-
-	// Create ProjectInfo for testing
-	projectInfo := ProjectInfo{
-		TempDir:    tempDir,
-		ExtractDir: extractDir,
-		BatchDir:   batchDir,
-		TxtarFile:  txtarFile,
+		batches = append(batches, []byte("-- "+tf.name+" --\n"+tf.content+"\n"))
 	}
 
 	// Save original stdin and create pipes for testing
@@ -139,28 +82,21 @@ func TestBatchWait(t *testing.T) {
 	processor := &ManifestProcessor{
 		logger:       logger,
 		debug:        false,
-		manifestFile: manifestFile,
+		manifestFile: ".nearwait.yml",
 		batchKBytes:  1, // Small batch size to ensure multiple batches
 		waitBatch:    true,
 		clipboard:    mockClipboard,
 	}
 
-	// Mock the createBatches function to return our predefined batch files
-	batchFiles := []string{
-		filepath.Join(batchDir, "batch_001.txtar"),
-		filepath.Join(batchDir, "batch_002.txtar"),
-		filepath.Join(batchDir, "batch_003.txtar"),
-	}
-
 	// Test processing with waitBatch
-	processor.processBatches(batchFiles, projectInfo)
+	processor.processBatches(batches)
 
 	// Close the stdout pipe
 	outW.Close()
 
 	// Read captured stdout
 	var outBuf bytes.Buffer
-	_, err = io.Copy(&outBuf, outR)
+	_, err := io.Copy(&outBuf, outR)
 	if err != nil {
 		t.Fatalf("Failed to read stdout: %v", err)
 	}
@@ -182,21 +118,16 @@ func TestBatchWait(t *testing.T) {
 }
 
 // Helper function for testing batch processing
-func (mp *ManifestProcessor) processBatches(batches []string, projectInfo ProjectInfo) error {
+func (mp *ManifestProcessor) processBatches(batches [][]byte) error {
 	// This is similar to the batch processing part in Process()
 	fmt.Printf("Created %d batches\n", len(batches))
 
-	for i, batchFile := range batches {
-		batchContent, err := os.ReadFile(batchFile)
-		if err != nil {
-			return err
-		}
-
+	for i, batchContent := range batches {
 		// If this is not the first batch and waitBatch is enabled, prompt user
 		if i > 0 && mp.waitBatch {
 			fmt.Printf("Press Enter to copy batch %d/%d...", i+1, len(batches))
 			reader := bufio.NewReader(os.Stdin)
-			_, err = reader.ReadString('\n') // Wait for Enter key
+			_, err := reader.ReadString('\n') // Wait for Enter key
 			if err != nil {
 				mp.logger.V(1).Info("Error reading user input", "error", err.Error())
 			}
@@ -213,3 +144,39 @@ func (mp *ManifestProcessor) processBatches(batches []string, projectInfo Projec
 
 	return nil
 }
+
+func TestVirtualFileClash(t *testing.T) {
+	project := fstest.MapFS{
+		"main.go":      {Data: []byte("package main\n")},
+		"changes.diff": {Data: []byte("old diff\n")},
+	}
+	tests := []struct {
+		name     string
+		manifest string
+		virtual  []string
+		wantErr  string
+	}{
+		{name: "distinct names", manifest: "filelist:\n- main.go\n- changes.diff\n", virtual: []string{"build.log"}},
+		{name: "clash with a commented file", manifest: "filelist:\n- main.go\n# - changes.diff\n", virtual: []string{"changes.diff"}},
+		{name: "clash with an enabled file", manifest: "filelist:\n- changes.diff\n", virtual: []string{"changes.diff"}, wantErr: "clashes"},
+		{name: "generated twice", manifest: "filelist:\n- main.go\n", virtual: []string{"build.log", "build.log"}, wantErr: "twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, _ := reportProcessor(t, project, tt.manifest)
+			for _, name := range tt.virtual {
+				processor.WithVirtualFile(name, []byte("generated\n"))
+			}
+			_, err := processor.Build(t.Context())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Build() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Build() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,55 +2,75 @@ package core
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+// ProjectInfo describes a single run over the enabled files
 type ProjectInfo struct {
-	Name       string
-	CWD        string
-	TempDir    string
-	TarFile    string
-	ExtractDir string
-	TxtarFile  string
-	BatchDir   string
+	// Files holds the staged enabled files, as they appear in the bundle
+	Files fs.FS
+	// TxtarFile names the txtar archive in the output sink
+	TxtarFile string
 }
 
-func (mp *ManifestProcessor) setupProjectInfo() (ProjectInfo, error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return ProjectInfo{}, fmt.Errorf("error getting current working directory: %w", err)
+func (mp *ManifestProcessor) setupProjectInfo(files fs.FS) ProjectInfo {
+	return ProjectInfo{
+		Files:     files,
+		TxtarFile: filepath.Base(txtarPathFor(mp.manifestFile)),
 	}
+}
 
-	projectName := filepath.Base(cwd)
+// keepDebugFiles copies the staged files and batches of bundle to a
+// temporary directory for inspection
+func (mp *ManifestProcessor) keepDebugFiles(bundle *Bundle) error {
+	projectDir, err := filepath.Abs(mp.dir)
+	if err != nil {
+		return fmt.Errorf("error getting the project directory: %w", err)
+	}
+	projectName := filepath.Base(projectDir)
 	tempDir := filepath.Join(os.TempDir(), fmt.Sprintf("nearwait_%s", projectName))
-	if err := os.MkdirAll(tempDir, 0o755); err != nil {
-		return ProjectInfo{}, fmt.Errorf("error creating temp directory: %w", err)
+	if err := os.RemoveAll(tempDir); err != nil {
+		return err
 	}
 
-	mp.logger.V(1).Info("Created temporary directory", "path", tempDir)
-
-	manifestBasename := filepath.Base(mp.manifestFile)
-	manifestBasename = strings.TrimSuffix(manifestBasename, filepath.Ext(manifestBasename))
-	txtarFilename := fmt.Sprintf("%s.txtar", manifestBasename)
-
-	batchDir := filepath.Join(tempDir, "batches")
-	if mp.batchKBytes > 0 {
-		if err := os.MkdirAll(batchDir, 0o755); err != nil {
-			return ProjectInfo{}, fmt.Errorf("error creating batch directory: %w", err)
+	sink := NewDirSink(tempDir)
+	err = fs.WalkDir(bundle.Files, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(bundle.Files, path)
+		if err != nil {
+			return err
 		}
+		return sink.WriteFile(filepath.Join(projectName, filepath.FromSlash(path)), data)
+	})
+	if err != nil {
+		return err
 	}
-
-	info := ProjectInfo{
-		Name:       projectName,
-		CWD:        cwd,
-		TempDir:    tempDir,
-		TarFile:    filepath.Join(tempDir, fmt.Sprintf("%s.tar", projectName)),
-		ExtractDir: filepath.Join(tempDir, projectName),
-		TxtarFile:  filepath.Join(filepath.Dir(mp.manifestFile), txtarFilename),
-		BatchDir:   batchDir,
+	for i, batch := range bundle.Batches {
+		if err := sink.WriteFile(filepath.Join("batches", batchName(i)), batch); err != nil {
+			return err
+		}
 	}
 
-	return info, nil
+	mp.report.addOutput(tempDir)
+	mp.logger.Info("Debug mode: Temporary directory kept for inspection", "path", tempDir)
+	return nil
+}
+
+// txtarPathFor returns the path of the txtar archive written next to manifestFile
+func txtarPathFor(manifestFile string) string {
+	manifestBasename := filepath.Base(manifestFile)
+	manifestBasename = strings.TrimSuffix(manifestBasename, filepath.Ext(manifestBasename))
+	return filepath.Join(filepath.Dir(manifestFile), fmt.Sprintf("%s.txtar", manifestBasename))
+}
+
+// IsOwnFile reports whether path is the manifest or the txtar archive
+// nearwait writes next to it, which selections should never include
+func IsOwnFile(manifestFile, path string) bool {
+	path = filepath.Clean(path)
+	return path == filepath.Clean(manifestFile) || path == filepath.Clean(txtarPathFor(manifestFile))
 }
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive, 1-based range of lines selected from a file
+type LineRange struct {
+	Start int
+	End   int
+}
+
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// rangeSpec matches the ":95-204" or ":10-20,42" suffix of a manifest entry
+var rangeSpec = regexp.MustCompile(`:(\d+(?:-\d+)?(?:,\d+(?:-\d+)?)*)$`)
+
+// splitRanges splits a manifest entry such as "core/processor.go:95-204" into
+// its path and line ranges
+func splitRanges(entry string) (string, []LineRange, error) {
+	m := rangeSpec.FindStringSubmatchIndex(entry)
+	if m == nil {
+		return entry, nil, nil
+	}
+	ranges, err := ParseLineRanges(entry[m[2]:m[3]])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid line range in %q: %w", entry, err)
+	}
+	return entry[:m[0]], ranges, nil
+}
+
+// ParseLineRanges parses a comma-separated list of ranges such as "10-20,42"
+func ParseLineRanges(spec string) ([]LineRange, error) {
+	var ranges []LineRange
+	for _, part := range strings.Split(spec, ",") {
+		startText, endText, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(startText)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(endText); err != nil {
+				return nil, err
+			}
+		}
+		if start < 1 || end < start {
+			return nil, fmt.Errorf("range %q must satisfy 1 <= start <= end", part)
+		}
+		ranges = append(ranges, LineRange{Start: start, End: end})
+	}
+	return mergeRanges(ranges), nil
+}
+
+// mergeRanges sorts ranges and joins the ones that overlap or touch
+func mergeRanges(ranges []LineRange) []LineRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sorted := append([]LineRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	merged := []LineRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End+1 {
+			last.End = max(last.End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func formatRanges(ranges []LineRange) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// extractRanges keeps only the selected lines of content and replaces every
+// elided region with an omission marker in the file's comment syntax
+func extractRanges(relPath string, content []byte, ranges []LineRange) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var b strings.Builder
+	next := 1
+	for _, r := range ranges {
+		if r.Start > len(lines) {
+			break
+		}
+		end := min(r.End, len(lines))
+		if r.Start > next {
+			b.WriteString(omittedMarker(relPath, r.Start-next) + "\n")
+		}
+		for _, line := range lines[r.Start-1 : end] {
+			b.WriteString(line)
+		}
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		next = end + 1
+	}
+	if next <= len(lines) {
+		b.WriteString(omittedMarker(relPath, len(lines)-next+1) + "\n")
+	}
+	return []byte(b.String())
+}
+
+// lineComments maps file extensions to their line comment prefix
+var lineComments = map[string]string{
+	".go": "//", ".c": "//", ".h": "//", ".cc": "//", ".cpp": "//", ".hpp": "//",
+	".cs": "//", ".java": "//", ".kt": "//", ".scala": "//", ".swift": "//",
+	".js": "//", ".jsx": "//", ".ts": "//", ".tsx": "//", ".mjs": "//", ".rs": "//",
+	".proto": "//", ".dart": "//", ".php": "//", ".zig": "//",
+	".py": "#", ".sh": "#", ".bash": "#", ".zsh": "#", ".rb": "#", ".pl": "#",
+	".yml": "#", ".yaml": "#", ".toml": "#", ".tf": "#", ".hcl": "#", ".r": "#",
+	".conf": "#", ".cfg": "#", ".mk": "#", ".nix": "#", ".ps1": "#",
+	".sql": "--", ".lua": "--", ".hs": "--", ".elm": "--",
+	".ini": ";", ".el": ";", ".clj": ";", ".lisp": ";", ".scm": ";",
+	".vim": "\"", ".tex": "%", ".erl": "%",
+}
+
+// blockComments maps file extensions without line comments to their block delimiters
+var blockComments = map[string][2]string{
+	".html": {"<!--", "-->"}, ".htm": {"<!--", "-->"}, ".xml": {"<!--", "-->"},
+	".md": {"<!--", "-->"}, ".svg": {"<!--", "-->"}, ".vue": {"<!--", "-->"},
+	".css": {"/*", "*/"}, ".scss": {"/*", "*/"}, ".less": {"/*", "*/"},
+}
+
+// hashCommentNames are extensionless files that use # comments
+var hashCommentNames = map[string]bool{
+	"Makefile": true, "Dockerfile": true, "Containerfile": true,
+	"Gemfile": true, "Rakefile": true, "Vagrantfile": true, ".gitignore": true,
+}
+
+// omittedMarker returns a comment noting that n lines were left out
+func omittedMarker(relPath string, n int) string {
+	text := fmt.Sprintf("... %d lines omitted ...", n)
+	if n == 1 {
+		text = "... 1 line omitted ..."
+	}
+
+	ext := strings.ToLower(filepath.Ext(relPath))
+	if prefix, ok := lineComments[ext]; ok {
+		return prefix + " " + text
+	}
+	if delims, ok := blockComments[ext]; ok {
+		return delims[0] + " " + text + " " + delims[1]
+	}
+	if hashCommentNames[filepath.Base(relPath)] {
+		return "# " + text
+	}
+	return text
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitRanges(t *testing.T) {
+	tests := []struct {
+		entry      string
+		wantPath   string
+		wantRanges []LineRange
+		wantErr    bool
+	}{
+		{"core/processor.go", "core/processor.go", nil, false},
+		{"core/processor.go:95-204", "core/processor.go", []LineRange{{95, 204}}, false},
+		{"a.go:50-60,1-10,42", "a.go", []LineRange{{1, 10}, {42, 42}, {50, 60}}, false},
+		{"a.go:1-10,5-20", "a.go", []LineRange{{1, 20}}, false},
+		{"a.go:20-10", "", nil, true},
+		{"notes:todo", "notes:todo", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			path, ranges, err := splitRanges(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if path != tt.wantPath || !reflect.DeepEqual(ranges, tt.wantRanges) {
+				t.Errorf("splitRanges() = %q, %v, want %q, %v", path, ranges, tt.wantPath, tt.wantRanges)
+			}
+		})
+	}
+}
+
+func TestExtractRanges(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, "line"+string(rune('0'+i%10)))
+	}
+	content := []byte(strings.Join(lines, "\n") + "\n")
+
+	got := string(extractRanges("main.go", content, []LineRange{{2, 3}, {5, 5}}))
+	want := "// ... 1 line omitted ...\nline2\nline3\n// ... 1 line omitted ...\nline5\n// ... 5 lines omitted ...\n"
+	if got != want {
+		t.Errorf("extractRanges() = %q, want %q", got, want)
+	}
+
+	got = string(extractRanges("index.html", content, []LineRange{{9, 20}}))
+	want = "<!-- ... 8 lines omitted ... -->\nline9\nline0\n"
+	if got != want {
+		t.Errorf("extractRanges() past EOF = %q, want %q", got, want)
+	}
+}
+
+func TestManifestRangesRoundTrip(t *testing.T) {
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	content := "filelist:\n- core/processor.go:95-204\n# - core/manifest.go:1-5,10-12  # binary\n- main.go\n"
+	if err := os.WriteFile(manifestFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	mg := NewManifestGenerator(testLogger(t))
+	manifest, err := mg.ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	if got := manifest.Ranges["core/processor.go"]; !reflect.DeepEqual(got, []LineRange{{95, 204}}) {
+		t.Errorf("Ranges[core/processor.go] = %v", got)
+	}
+
+	if err := mg.WriteManifest(manifest, manifestFile); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+	written, err := os.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	want := "filelist:\n# - core/manifest.go:1-5,10-12  # binary\n- core/processor.go:95-204\n- main.go\n"
+	if string(written) != want {
+		t.Errorf("WriteManifest() wrote %q, want %q", written, want)
+	}
+}
+
+func TestRangesWithSymbols(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"enabled", "filelist:\n- core/processor.go:95-204#Process\n", true},
+		{"commented", "filelist:\n# - core/processor.go:95-204#Process\n", false},
+		{"separate entries", "filelist:\n- core/processor.go:95-204\n- core/manifest.go#Manifest\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+			if err := os.WriteFile(manifestFile, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := NewManifestGenerator(testLogger(t)).ReadManifest(manifestFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	if err := os.WriteFile(manifestFile, []byte("filelist:\n- main.go#main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewManifestGenerator(testLogger(t)).EnableFileRanges(manifestFile, map[string][]LineRange{"main.go": {{1, 5}}})
+	if err == nil {
+		t.Error("EnableFileRanges() on a symbol entry succeeded, want an error")
+	}
+}
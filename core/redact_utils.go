@@ -0,0 +1,385 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrSecretsFound is returned when high-confidence secrets were redacted and
+// copying them to the clipboard was not explicitly allowed
+var ErrSecretsFound = errors.New("high-confidence secrets found")
+
+// allowlistFile is read from the manifest directory to silence known false positives
+const allowlistFile = ".nearwait-allowlist"
+
+// sensitiveNote marks files that are kept commented because their name suggests secrets
+const sensitiveNote = "sensitive"
+
+type secretRule struct {
+	id      string
+	pattern *regexp.Regexp
+	group   int  // submatch holding the secret, 0 for the whole match
+	high    bool // high-confidence rules block copying to the clipboard
+}
+
+var secretRules = []secretRule{
+	{"private-key", regexp.MustCompile(`-----BEGIN[A-Z ]*PRIVATE KEY-----[\s\S]*?-----END[A-Z ]*PRIVATE KEY-----`), 0, true},
+	{"aws-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), 0, true},
+	{"aws-secret", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`), 1, true},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`), 0, true},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`), 0, true},
+	{"stripe-key", regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{16,}\b`), 0, true},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`), 0, true},
+	{"openai-key", regexp.MustCompile(`\bsk-(?:proj-|ant-[a-z0-9]+-)?[A-Za-z0-9_\-]{32,}\b`), 0, true},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), 0, false},
+	{"generic-secret", regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|token|passw(?:or)?d|pwd)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`), 1, false},
+}
+
+// highEntropyToken matches candidates for the entropy heuristic
+var highEntropyToken = regexp.MustCompile(`[A-Za-z0-9+/_\-]{24,}={0,2}`)
+
+// entropyThreshold is the Shannon entropy in bits per character above which a
+// token is treated as a likely secret
+const entropyThreshold = 4.3
+
+// entropyExemptFiles hold checksums that look random by design
+var entropyExemptFiles = map[string]bool{
+	"go.sum":            true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+	"poetry.lock":       true,
+	"Gemfile.lock":      true,
+	"composer.lock":     true,
+}
+
+// sensitiveNames are file name patterns that are kept commented in the manifest
+var sensitiveNames = []string{
+	".env", ".env.*", "*.env",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+	"credentials", "credentials.json", "service-account*.json",
+	".netrc", ".npmrc", ".pypirc", ".htpasswd", ".pgpass",
+	"secrets.json", "secrets.yml", "secrets.yaml", "*.tfvars",
+}
+
+// sensitiveExceptions are templates that match sensitiveNames but hold no secrets
+var sensitiveExceptions = []string{".env.example", ".env.sample", ".env.template", "*.pub"}
+
+// SecretFinding describes a secret that was replaced with a placeholder
+type SecretFinding struct {
+	Path        string
+	Line        int
+	Rule        string
+	Placeholder string
+	High        bool
+}
+
+// Allowlist silences secret detection for known-safe files, rules and values
+type Allowlist struct {
+	files  []string
+	rules  map[string]bool
+	values []*regexp.Regexp
+}
+
+// LoadAllowlist reads an allowlist file. Each line is one of
+//
+//	file: <glob>    allow a sensitive file and skip redaction in it
+//	rule: <id>      disable a detection rule
+//	value: <regex>  never redact secrets matching regex
+//
+// A line without a prefix is treated as a value regex. A missing file yields
+// an empty allowlist.
+func LoadAllowlist(allowlistPath string) (Allowlist, error) {
+	allowlist := Allowlist{rules: make(map[string]bool)}
+
+	file, err := os.Open(allowlistPath)
+	if os.IsNotExist(err) {
+		return allowlist, nil
+	}
+	if err != nil {
+		return allowlist, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kind, value, found := strings.Cut(line, ":")
+		kind, value = strings.TrimSpace(kind), strings.TrimSpace(value)
+		switch {
+		case found && kind == "file":
+			allowlist.files = append(allowlist.files, filepath.ToSlash(value))
+		case found && kind == "rule":
+			allowlist.rules[value] = true
+		default:
+			if found && kind == "value" {
+				line = value
+			}
+			re, err := regexp.Compile(line)
+			if err != nil {
+				return allowlist, fmt.Errorf("invalid allowlist pattern %q: %w", line, err)
+			}
+			allowlist.values = append(allowlist.values, re)
+		}
+	}
+	return allowlist, scanner.Err()
+}
+
+func allowlistPathFor(manifestFile string) string {
+	return filepath.Join(filepath.Dir(manifestFile), allowlistFile)
+}
+
+func (a Allowlist) allowsFile(file string) bool {
+	file = filepath.ToSlash(file)
+	for _, pattern := range a.files {
+		if ok, _ := path.Match(pattern, file); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(file)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Allowlist) allowsSecret(rule, value string) bool {
+	if a.rules[rule] {
+		return true
+	}
+	for _, re := range a.values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSensitiveFile reports whether the name of file suggests it holds credentials
+func isSensitiveFile(file string) bool {
+	base := filepath.Base(file)
+	for _, pattern := range sensitiveExceptions {
+		if ok, _ := path.Match(pattern, base); ok {
+			return false
+		}
+	}
+	for _, pattern := range sensitiveNames {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// commentSensitiveFiles adds files with credential-like names commented
+// unless the allowlist names them. Files in known were already in the
+// manifest and keep the state the user gave them.
+func (mg *ManifestGenerator) commentSensitiveFiles(manifest Manifest, allowlist Allowlist, known map[string]bool) Manifest {
+	if manifest.Notes == nil {
+		manifest.Notes = make(map[string]string)
+	}
+	for file := range manifest.FileList {
+		if !isSensitiveFile(file) || allowlist.allowsFile(file) {
+			continue
+		}
+		if _, ok := known[file]; ok {
+			continue
+		}
+		if !manifest.FileList[file] {
+			mg.logger.Info("Commenting out sensitive file", "path", file)
+		}
+		manifest.FileList[file] = true
+		manifest.Notes[file] = sensitiveNote
+	}
+	return manifest
+}
+
+// Redactor replaces secrets in file contents with stable placeholders such as
+// <REDACTED:aws-key:1>; the same secret always maps to the same placeholder
+type Redactor struct {
+	allowlist    Allowlist
+	placeholders map[string]string
+	counts       map[string]int
+	findings     map[string]SecretFinding
+}
+
+func NewRedactor(allowlist Allowlist) *Redactor {
+	return &Redactor{
+		allowlist:    allowlist,
+		placeholders: make(map[string]string),
+		counts:       make(map[string]int),
+		findings:     make(map[string]SecretFinding),
+	}
+}
+
+// redaction is a span of the original content replaced by a placeholder
+type redaction struct {
+	start, end  int
+	placeholder string
+}
+
+// Redact returns content with every detected secret replaced. All rules match
+// against the original content, so line numbers stay right even after a
+// multi-line secret such as a private key; earlier rules win overlapping spans.
+func (r *Redactor) Redact(file string, content []byte) []byte {
+	if r == nil || r.allowlist.allowsFile(file) {
+		return content
+	}
+
+	text := string(content)
+	var spans []redaction
+	for _, rule := range secretRules {
+		if r.allowlist.rules[rule.id] {
+			continue
+		}
+		spans = r.match(file, text, spans, rule.id, rule.high, rule.pattern, rule.group, nil)
+	}
+	if !entropyExemptFiles[filepath.Base(file)] && !r.allowlist.rules["high-entropy"] {
+		spans = r.match(file, text, spans, "high-entropy", false, highEntropyToken, 0, isHighEntropy)
+	}
+	if len(spans) == 0 {
+		return content
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		b.WriteString(text[last:span.start])
+		b.WriteString(span.placeholder)
+		last = span.end
+	}
+	b.WriteString(text[last:])
+	return []byte(b.String())
+}
+
+// match records the secrets re finds in text outside the spans already
+// matched and returns spans with them added
+func (r *Redactor) match(file, text string, spans []redaction, rule string, high bool, re *regexp.Regexp, group int, accept func(string) bool) []redaction {
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 || overlapsRedaction(spans, start, end) {
+			continue
+		}
+		secret := text[start:end]
+		if strings.HasPrefix(secret, "<REDACTED:") {
+			continue
+		}
+		if r.allowlist.allowsSecret(rule, secret) || (accept != nil && !accept(secret)) {
+			continue
+		}
+		placeholder := r.placeholder(rule, secret)
+		line := strings.Count(text[:start], "\n") + 1
+		r.findings[fmt.Sprintf("%s:%d:%s", file, line, placeholder)] = SecretFinding{
+			Path:        file,
+			Line:        line,
+			Rule:        rule,
+			Placeholder: placeholder,
+			High:        high,
+		}
+		spans = append(spans, redaction{start: start, end: end, placeholder: placeholder})
+	}
+	return spans
+}
+
+func overlapsRedaction(spans []redaction, start, end int) bool {
+	for _, span := range spans {
+		if start < span.end && span.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) placeholder(rule, secret string) string {
+	if placeholder, ok := r.placeholders[secret]; ok {
+		return placeholder
+	}
+	r.counts[rule]++
+	placeholder := fmt.Sprintf("<REDACTED:%s:%d>", rule, r.counts[rule])
+	r.placeholders[secret] = placeholder
+	return placeholder
+}
+
+// Findings returns every redaction made so far, ordered by path and line
+func (r *Redactor) Findings() []SecretFinding {
+	if r == nil {
+		return nil
+	}
+	findings := make([]SecretFinding, 0, len(r.findings))
+	for _, f := range r.findings {
+		findings = append(findings, f)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Placeholder < findings[j].Placeholder
+	})
+	return findings
+}
+
+// HighConfidence returns the findings produced by high-confidence rules
+func (r *Redactor) HighConfidence() []SecretFinding {
+	var high []SecretFinding
+	for _, f := range r.Findings() {
+		if f.High {
+			high = append(high, f)
+		}
+	}
+	return high
+}
+
+// isHighEntropy reports whether token mixes letter cases and digits and looks random
+func isHighEntropy(token string) bool {
+	var upper, lower, digit bool
+	for _, c := range token {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && shannonEntropy(token) >= entropyThreshold
+}
+
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func secretsError(findings []SecretFinding) error {
+	var locations []string
+	for _, f := range findings {
+		locations = append(locations, fmt.Sprintf("%s:%d (%s)", f.Path, f.Line, f.Rule))
+	}
+	return fmt.Errorf("%w in %s; add known false positives to %s",
+		ErrSecretsFound, strings.Join(locations, ", "), allowlistFile)
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+)
+
+// renderFiles walks the staged files and renders every file into a txtar
+// section, ordered by the configured strategy
+func (mp *ManifestProcessor) renderFiles(ctx context.Context, staged fs.FS) ([]txtar.File, error) {
+	var rendered []renderedFile
+	var reported []ReportFile
+
+	err := fs.WalkDir(staged, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		relPath := filepath.FromSlash(path)
+		content, err := fs.ReadFile(staged, path)
+		if err != nil {
+			return err
+		}
+
+		section, ok := mp.renderFile(relPath, content)
+		if !ok {
+			reported = append(reported, mp.reportRendered(relPath, content, nil))
+			return nil
+		}
+		rendered = append(rendered, renderedFile{path: relPath, content: content, section: section})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mp.orderFiles(rendered)
+
+	files := make([]txtar.File, len(rendered))
+	for i, f := range rendered {
+		files[i] = f.section
+		reported = append(reported, mp.reportRendered(f.path, f.content, &files[i]))
+	}
+
+	// Files generated by nearwait itself follow the project files
+	for _, f := range mp.virtualFiles {
+		data := mp.redactor.Redact(f.Name, f.Data)
+		files = append(files, txtar.File{Name: f.Name, Data: data})
+		reported = append(reported, ReportFile{Path: f.Name, Status: FileGenerated, Bytes: len(data), Tokens: EstimateTokens(data)})
+	}
+	mp.rendered = reported
+	return files, nil
+}
+
+// renderFile turns the contents of a single file into its txtar section.
+// Partial files are named after their entry, e.g. "core/processor.go:95-204",
+// so the reader knows the section is incomplete. It returns false when the
+// file has nothing to contribute.
+func (mp *ManifestProcessor) renderFile(relPath string, content []byte) (txtar.File, bool) {
+	name := relPath
+	// Entries are looked up without the revision prefix of the section
+	entry := mp.entryPath(relPath)
+
+	if symbols, fromPackage := mp.symbolsFor(entry); len(symbols) > 0 && filepath.Ext(relPath) == ".go" {
+		extracted, found, err := extractGoSymbols(relPath, content, symbols)
+		switch {
+		case err != nil:
+			mp.logger.Info("Failed to parse Go file, including it in full", "file", relPath, "error", err.Error())
+		case len(found) == 0 && fromPackage:
+			return txtar.File{}, false
+		case len(found) == 0:
+			mp.logger.Info("No requested symbols found, including file in full", "file", relPath, "symbols", symbols)
+		default:
+			content = extracted
+			name = relPath + "#" + strings.Join(found, ",")
+		}
+	}
+
+	if name == relPath && len(mp.manifest.Ranges[entry]) == 0 && mp.outlineFor(entry) {
+		if outlined, err := outlineGoFile(relPath, content); err != nil {
+			mp.logger.Info("Failed to parse Go file, including it in full", "file", relPath, "error", err.Error())
+		} else {
+			content = outlined
+			name = relPath + outlineSuffix
+		}
+	}
+
+	if isBinary(content) {
+		policy := mp.binaryPolicyFor(entry)
+		mp.logger.V(1).Info("Rendering binary file", "file", relPath, "policy", policy)
+		content = renderBinary(relPath, content, policy)
+	} else {
+		if ranges := mp.manifest.Ranges[entry]; len(ranges) > 0 && name == relPath {
+			content = extractRanges(relPath, content, ranges)
+			name = relPath + ":" + formatRanges(ranges)
+		}
+		content = mp.redactor.Redact(relPath, content)
+	}
+
+	return txtar.File{
+		Name: name,
+		Data: content,
+	}, true
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/txtar"
+)
+
+// ErrOverBudget is returned when the archive is estimated to hold more tokens
+// than the budget set with WithMaxTokens
+var ErrOverBudget = errors.New("archive is over the token budget")
+
+// ErrClipboardFailed is returned when the archive or a batch could not be
+// copied to the clipboard
+var ErrClipboardFailed = errors.New("error copying to clipboard")
+
+// File statuses in a Report
+const (
+	// FileIncluded is an enabled file rendered into the archive
+	FileIncluded = "included"
+	// FileRedacted is an included file in which secrets were redacted
+	FileRedacted = "redacted"
+	// FileGenerated is a file produced by nearwait, such as changes.diff
+	FileGenerated = "generated"
+	// FileSkipped is an enabled file that contributed nothing to the archive
+	FileSkipped = "skipped"
+	// FileMissing is an enabled file that does not exist in its source
+	FileMissing = "missing"
+)
+
+// Clipboard statuses in a Report
+const (
+	// ClipboardCopied is a copy that reached the clipboard
+	ClipboardCopied = "copied"
+	// ClipboardUnavailable is a copy skipped because the system has no
+	// clipboard; the run still succeeds
+	ClipboardUnavailable = "no_clipboard"
+	// ClipboardFailed is a copy the clipboard rejected
+	ClipboardFailed = "failed"
+)
+
+// Report describes a run for editors and scripts that drive nearwait. Build
+// and Process fill it in when it is set with WithReport; the caller owns the
+// manifest, root and status fields.
+type Report struct {
+	Manifest  string            `json:"manifest"`
+	Root      string            `json:"root"`
+	Status    string            `json:"status"`
+	ExitCode  int               `json:"exit_code"`
+	Error     string            `json:"error,omitempty"`
+	Bytes     int               `json:"bytes"`
+	Tokens    int               `json:"tokens"`
+	Files     []ReportFile      `json:"files"`
+	Batches   []ReportBatch     `json:"batches,omitempty"`
+	Outputs   []string          `json:"outputs"`
+	Clipboard []ClipboardResult `json:"clipboard,omitempty"`
+	Stages    []StageTiming     `json:"stages"`
+}
+
+// ReportFile describes what happened to a single file
+type ReportFile struct {
+	Path string `json:"path"`
+	// Section names the file in the archive when it differs from Path, e.g.
+	// "core/processor.go:95-204"
+	Section string `json:"section,omitempty"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Bytes   int    `json:"bytes"`
+	Tokens  int    `json:"tokens"`
+	// Batch names the batch archive holding the file
+	Batch string `json:"batch,omitempty"`
+}
+
+// ReportBatch lists the sections of a batch archive
+type ReportBatch struct {
+	Name   string   `json:"name"`
+	Files  []string `json:"files"`
+	Bytes  int      `json:"bytes"`
+	Tokens int      `json:"tokens"`
+}
+
+// ClipboardResult records a single copy to the clipboard
+type ClipboardResult struct {
+	Backend string `json:"backend"`
+	Batch   string `json:"batch,omitempty"`
+	Bytes   int    `json:"bytes"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// StageTiming records how long a stage of the run took
+type StageTiming struct {
+	Stage      string  `json:"stage"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// EstimateTokens approximates the number of model tokens in data at four
+// bytes per token
+func EstimateTokens(data []byte) int {
+	return (len(data) + 3) / 4
+}
+
+// WithReport records the details of each run into report
+func (mp *ManifestProcessor) WithReport(report *Report) *ManifestProcessor {
+	mp.report = report
+	return mp
+}
+
+// WithMaxTokens fails a run whose archive is estimated to hold more than
+// maxTokens tokens; 0 means no budget
+func (mp *ManifestProcessor) WithMaxTokens(maxTokens int) *ManifestProcessor {
+	mp.maxTokens = maxTokens
+	return mp
+}
+
+// reset clears what an earlier run recorded, keeping the fields owned by
+// the caller
+func (r *Report) reset() {
+	if r == nil {
+		return
+	}
+	*r = Report{Manifest: r.Manifest, Root: r.Root}
+}
+
+// timeStage records the time spent in stage since start
+func (r *Report) timeStage(stage string, start time.Time) {
+	if r == nil {
+		return
+	}
+	r.Stages = append(r.Stages, StageTiming{
+		Stage:      stage,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	})
+}
+
+func (r *Report) addOutput(output string) {
+	if r == nil {
+		return
+	}
+	r.Outputs = append(r.Outputs, output)
+}
+
+func (r *Report) addClipboard(result ClipboardResult) {
+	if r == nil {
+		return
+	}
+	r.Clipboard = append(r.Clipboard, result)
+}
+
+// setArchive records the rendered files after the files that were missing or
+// skipped while staging, replacing earlier renders
+func (r *Report) setArchive(notes, rendered []ReportFile, archive []byte) {
+	if r == nil {
+		return
+	}
+	r.Files = append(append([]ReportFile{}, notes...), rendered...)
+	r.Bytes = len(archive)
+	r.Tokens = EstimateTokens(archive)
+}
+
+// setBatches records the batch layout and the batch of every file
+func (r *Report) setBatches(batches []ReportBatch) {
+	if r == nil {
+		return
+	}
+	r.Batches = batches
+	batchOf := make(map[string]string)
+	for _, batch := range batches {
+		for _, name := range batch.Files {
+			batchOf[name] = batch.Name
+		}
+	}
+	for i, f := range r.Files {
+		name := f.Section
+		if name == "" {
+			name = f.Path
+		}
+		r.Files[i].Batch = batchOf[name]
+	}
+}
+
+// reportRendered describes a rendered file, marking it redacted when secrets
+// were found in it
+func (mp *ManifestProcessor) reportRendered(relPath string, content []byte, section *txtar.File) ReportFile {
+	f := ReportFile{Path: filepath.ToSlash(relPath), Status: FileIncluded}
+	if section == nil {
+		f.Status = FileSkipped
+		f.Reason = "no requested symbols"
+		return f
+	}
+	if section.Name != f.Path {
+		f.Section = section.Name
+	}
+	f.Bytes = len(section.Data)
+	f.Tokens = EstimateTokens(section.Data)
+
+	if isBinary(content) {
+		policy := mp.binaryPolicyFor(mp.entryPath(relPath))
+		if policy == BinarySkip {
+			f.Status = FileSkipped
+			f.Reason = "binary"
+			return f
+		}
+		f.Reason = fmt.Sprintf("binary, rendered as %s", policy)
+	}
+
+	var rules []string
+	for _, finding := range mp.redactor.Findings() {
+		if finding.Path == relPath {
+			rules = append(rules, finding.Rule)
+		}
+	}
+	if len(rules) > 0 {
+		f.Status = FileRedacted
+		f.Reason = fmt.Sprintf("%d secrets redacted: %s", len(rules), strings.Join(uniqueSorted(rules), ", "))
+	}
+	return f
+}
+
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// describeOutput names where output stores name, such as a path on disk
+func describeOutput(output OutputSink, name string) string {
+	switch sink := output.(type) {
+	case *DirSink:
+		target := filepath.Join(sink.Dir, name)
+		if abs, err := filepath.Abs(target); err == nil {
+			return abs
+		}
+		return target
+	case *MemorySink:
+		return "memory:" + name
+	}
+	return name
+}
+
+// clipboardBackend names the clipboard implementation for the report
+func clipboardBackend(c ClipboardWriter) string {
+	switch c.(type) {
+	case *SystemClipboard:
+		return "system"
+	case *NoopClipboard:
+		return "noop"
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+func overBudgetError(tokens, maxTokens int) error {
+	return fmt.Errorf("%w: about %d tokens, budget %d", ErrOverBudget, tokens, maxTokens)
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// failingClipboard rejects every copy with err
+type failingClipboard struct{ err error }
+
+func (c *failingClipboard) WriteAll(text string) error { return c.err }
+
+func (c *failingClipboard) ShouldDelay() bool { return false }
+
+// reportProcessor returns a processor over project for the manifest
+func reportProcessor(t *testing.T, project fstest.MapFS, manifest string) (*ManifestProcessor, *Report) {
+	t.Helper()
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report := &Report{Manifest: manifestFile, Root: "project"}
+	processor := NewManifestProcessor(testLogger(t), false, manifestFile).
+		WithFS(project).
+		WithOutput(NewMemorySink()).
+		WithNoopClipboard().
+		WithReport(report)
+	return processor, report
+}
+
+func TestReport(t *testing.T) {
+	project := fstest.MapFS{
+		"main.go":    {Data: []byte("package main\n")},
+		"config.ini": {Data: []byte(`password = "hunter2hunter2"` + "\n")},
+		"logo.png":   {Data: []byte("\x89PNG\r\n\x1a\n\x00\x00")},
+	}
+	processor, report := reportProcessor(t, project, "filelist:\n- main.go\n- config.ini\n- logo.png\n- gone.go\n")
+	processor.WithBatchKBytes(1).WithVirtualFile("build.log", []byte("ok\n"))
+
+	if isEmpty, err := processor.Process(); err != nil || isEmpty {
+		t.Fatalf("Process() = %v, %v", isEmpty, err)
+	}
+
+	statuses := make(map[string]string)
+	for _, f := range report.Files {
+		statuses[f.Path] = f.Status
+		if f.Status != FileMissing && f.Batch != "batch_001.txtar" {
+			t.Errorf("%s is in batch %q, want batch_001.txtar", f.Path, f.Batch)
+		}
+	}
+	want := map[string]string{
+		"gone.go":    FileMissing,
+		"main.go":    FileIncluded,
+		"config.ini": FileRedacted,
+		"logo.png":   FileSkipped,
+		"build.log":  FileGenerated,
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("file statuses = %v, want %v", statuses, want)
+	}
+	if report.Bytes == 0 || report.Tokens != (report.Bytes+3)/4 {
+		t.Errorf("Bytes = %d, Tokens = %d", report.Bytes, report.Tokens)
+	}
+	if len(report.Batches) != 1 || len(report.Batches[0].Files) != 4 {
+		t.Errorf("Batches = %+v, want one batch of 4 files", report.Batches)
+	}
+	if want := []string{"memory:.nearwait.txtar"}; !reflect.DeepEqual(report.Outputs, want) {
+		t.Errorf("Outputs = %v, want %v", report.Outputs, want)
+	}
+	if want := []ClipboardResult{{Backend: "noop", Batch: "batch_001.txtar", Bytes: report.Batches[0].Bytes, Status: ClipboardCopied}}; !reflect.DeepEqual(report.Clipboard, want) {
+		t.Errorf("Clipboard = %+v, want %+v", report.Clipboard, want)
+	}
+	var stages []string
+	for _, s := range report.Stages {
+		stages = append(stages, s.Stage)
+	}
+	if want := []string{"read_manifest", "generate", "stage", "render", "batch", "clipboard"}; !reflect.DeepEqual(stages, want) {
+		t.Errorf("Stages = %v, want %v", stages, want)
+	}
+
+	// A second run starts a fresh report
+	if _, err := processor.Process(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Stages) != 6 || len(report.Clipboard) != 1 || report.Manifest == "" {
+		t.Errorf("second run report = %+v, want it reset", report)
+	}
+}
+
+func TestReportErrors(t *testing.T) {
+	project := fstest.MapFS{"main.go": {Data: []byte("package main\n\nfunc main() {}\n")}}
+
+	processor, _ := reportProcessor(t, project, "filelist:\n- main.go\n")
+	if _, err := processor.WithMaxTokens(5).Process(); !errors.Is(err, ErrOverBudget) {
+		t.Errorf("Process() over budget error = %v, want ErrOverBudget", err)
+	}
+
+	processor, report := reportProcessor(t, project, "filelist:\n- main.go\n")
+	if _, err := processor.WithClipboard(&failingClipboard{errors.New("xclip: cannot open display")}).Process(); !errors.Is(err, ErrClipboardFailed) {
+		t.Errorf("Process() clipboard error = %v, want ErrClipboardFailed", err)
+	}
+	if len(report.Clipboard) != 1 || report.Clipboard[0].Status != ClipboardFailed || report.Clipboard[0].Error != "xclip: cannot open display" {
+		t.Errorf("Clipboard = %+v, want the failure recorded", report.Clipboard)
+	}
+
+	// A system without a clipboard is not a failure
+	processor, report = reportProcessor(t, project, "filelist:\n- main.go\n")
+	if _, err := processor.WithClipboard(&failingClipboard{ErrNoClipboard}).Process(); err != nil {
+		t.Errorf("Process() without a clipboard error = %v, want nil", err)
+	}
+	if len(report.Clipboard) != 1 || report.Clipboard[0].Status != ClipboardUnavailable || report.Clipboard[0].Error != "" {
+		t.Errorf("Clipboard = %+v, want the missing clipboard recorded", report.Clipboard)
+	}
+
+	processor, _ = reportProcessor(t, project, "filelist:\n# - main.go\n")
+	if _, err := processor.Build(t.Context()); !errors.Is(err, ErrEmptyManifest) {
+		t.Errorf("Build() error = %v, want ErrEmptyManifest", err)
+	}
+}
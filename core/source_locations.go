@@ -0,0 +1,94 @@
+package core
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceLocation is a span of lines in a source file mentioned by a stack
+// trace, a diagnostic or a coverage profile
+type SourceLocation struct {
+	Path  string
+	Lines LineRange
+}
+
+// ResolveLocations maps the paths of locs onto files of the project,
+// dropping locations in the Go installation, the module cache or outside the
+// project directory set with WithDir. Bare or partial paths, such as the file names go test
+// prints, are matched against the end of project paths when unambiguous.
+func (mg *ManifestGenerator) ResolveLocations(locs []SourceLocation) ([]SourceLocation, error) {
+	currentFiles, err := mg.GetCurrentFiles()
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(mg.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ignored []string
+	for _, dir := range []string{os.Getenv("GOROOT"), build.Default.GOROOT, goModCache()} {
+		if dir != "" {
+			ignored = append(ignored, filepath.Clean(dir))
+		}
+	}
+
+	var resolved []SourceLocation
+	for _, loc := range locs {
+		path, ok := resolveProjectPath(filepath.FromSlash(loc.Path), root, ignored, currentFiles)
+		if !ok {
+			mg.logger.V(1).Info("Ignoring location outside the project", "path", loc.Path, "line", loc.Lines.Start)
+			continue
+		}
+		resolved = append(resolved, SourceLocation{Path: path, Lines: loc.Lines})
+	}
+	return resolved, nil
+}
+
+func resolveProjectPath(path, root string, ignored []string, currentFiles map[string]bool) (string, bool) {
+	if filepath.IsAbs(path) {
+		for _, dir := range ignored {
+			if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return "", false
+			}
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		return rel, currentFiles[rel]
+	}
+
+	path = filepath.Clean(path)
+	if currentFiles[path] {
+		return path, true
+	}
+	var match string
+	for file := range currentFiles {
+		if strings.HasSuffix(file, string(filepath.Separator)+path) {
+			if match != "" {
+				return "", false
+			}
+			match = file
+		}
+	}
+	return match, match != ""
+}
+
+// LocationRanges groups locations by file, widening each by context lines on
+// both sides. A negative context selects whole files, represented by nil
+// ranges.
+func LocationRanges(locs []SourceLocation, context int) map[string][]LineRange {
+	files := make(map[string][]LineRange)
+	for _, loc := range locs {
+		if context < 0 {
+			files[loc.Path] = nil
+			continue
+		}
+		r := LineRange{Start: max(loc.Lines.Start-context, 1), End: loc.Lines.End + context}
+		files[loc.Path] = mergeRanges(append(files[loc.Path], r))
+	}
+	return files
+}
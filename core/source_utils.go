@@ -0,0 +1,191 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WorktreeRev names the working copy in a list of revisions
+const WorktreeRev = "."
+
+// fileSource is a tree the enabled entries are read from, such as a git
+// revision, placed under prefix in the bundle
+type fileSource struct {
+	prefix string
+	fsys   fs.FS
+}
+
+// OpenDirFS serves the directory tree at dir. Unlike os.DirFS, symbolic links
+// cannot lead outside dir: opening one that does fails. The returned closer
+// releases the directory once the file system is no longer used.
+func OpenDirFS(dir string) (fs.FS, io.Closer, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return root.FS(), root, nil
+}
+
+// dirRoots collects the directories opened by OpenDirFS so they can be
+// closed together
+type dirRoots []io.Closer
+
+// Close closes every directory, returning the errors joined
+func (r *dirRoots) Close() error {
+	var errs []error
+	for _, root := range *r {
+		errs = append(errs, root.Close())
+	}
+	*r = nil
+	return errors.Join(errs...)
+}
+
+// closeSources releases the directories opened for the sources of a build
+func (mp *ManifestProcessor) closeSources() {
+	if err := mp.roots.Close(); err != nil {
+		mp.logger.V(1).Info("Failed to close source directory", "error", err.Error())
+	}
+	mp.sources = nil
+}
+
+// WithRevisions reads the enabled entries from git revisions instead of the
+// working copy; "." stands for the working copy itself. With prefixed set,
+// every revision is placed under a top-level directory named after it, which
+// is required to combine several revisions in one bundle.
+func (mp *ManifestProcessor) WithRevisions(revs []string, prefixed bool) *ManifestProcessor {
+	mp.revisions = revs
+	mp.revPrefix = prefixed
+	return mp
+}
+
+// fileSources opens the trees the enabled entries are read from, each with
+// the directories declared by the mounts setting
+func (mp *ManifestProcessor) fileSources() ([]fileSource, error) {
+	sources, err := mp.baseSources()
+	if err != nil {
+		return nil, err
+	}
+	mounts, roots, err := mountSettings(mp.manifest.Settings, mp.dir)
+	mp.roots = append(mp.roots, roots...)
+	if err != nil {
+		return nil, fmt.Errorf("error mounting directories: %w", err)
+	}
+	for i := range sources {
+		if sources[i].fsys, err = newMountFS(sources[i].fsys, mounts); err != nil {
+			return nil, fmt.Errorf("error mounting directories: %w", err)
+		}
+	}
+	return sources, nil
+}
+
+// baseSources opens the configured source or revisions, or else the working
+// copy
+func (mp *ManifestProcessor) baseSources() ([]fileSource, error) {
+	if mp.fsys != nil {
+		// Diffs and history are read from the git repository of the project
+		// directory, which the file system is not part of
+		switch {
+		case len(mp.revisions) > 0:
+			return nil, errors.New("revisions cannot be combined with a source archive")
+		case mp.diffRef != "", mp.historyLimit > 0:
+			return nil, errors.New("diffs and history cannot be combined with a source archive")
+		}
+		return []fileSource{{fsys: mp.fsys}}, nil
+	}
+	worktree, root, err := OpenDirFS(mp.dir)
+	if err != nil {
+		return nil, err
+	}
+	mp.roots = append(mp.roots, root)
+	if len(mp.revisions) == 0 {
+		return []fileSource{{fsys: worktree}}, nil
+	}
+	if mp.diffRef != "" || mp.historyLimit > 0 {
+		// Diffs and history describe the working copy, not the files of a
+		// revision
+		for _, rev := range mp.revisions {
+			if rev != WorktreeRev {
+				return nil, fmt.Errorf("diffs and history cannot be combined with revision %s", rev)
+			}
+		}
+	}
+	if len(mp.revisions) > 1 && !mp.revPrefix {
+		return nil, errors.New("combining several revisions requires a prefix per revision")
+	}
+
+	var repo *GitRepo
+	var sources []fileSource
+	seen := make(map[string]bool)
+	for _, rev := range mp.revisions {
+		source := fileSource{fsys: worktree}
+		if rev != WorktreeRev {
+			if repo == nil {
+				var err error
+				if repo, err = OpenGitRepo(mp.dir); err != nil {
+					return nil, err
+				}
+			}
+			fsys, err := repo.RevisionFS(rev)
+			if err != nil {
+				return nil, err
+			}
+			source.fsys = fsys
+		}
+		if mp.revPrefix {
+			source.prefix = revisionDir(rev)
+			if seen[source.prefix] {
+				return nil, fmt.Errorf("revision %s is listed twice", rev)
+			}
+			seen[source.prefix] = true
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// checkEntryPaths rejects enabled entries outside the project root, such as
+// ../shared/lib.go, which have to be reached through a mount instead
+func checkEntryPaths(manifest Manifest) error {
+	for file, isCommented := range manifest.FileList {
+		if !isCommented && !fs.ValidPath(filepath.ToSlash(file)) {
+			return fmt.Errorf("manifest entry %s is outside the project root; declare a mount for its directory, e.g. %s: {name: dir}", file, mountsSetting)
+		}
+	}
+	return nil
+}
+
+// statEntry describes a project file as found in the first source
+func (mp *ManifestProcessor) statEntry(name string) (fs.FileInfo, error) {
+	if len(mp.sources) == 0 {
+		return os.Stat(filepath.Join(mp.dir, name))
+	}
+	return fs.Stat(mp.sources[0].fsys, filepath.ToSlash(name))
+}
+
+// revisionDir names the top-level directory of a revision, e.g. "v0.1.0" or
+// "origin-main"
+func revisionDir(rev string) string {
+	if rev == WorktreeRev {
+		return "worktree"
+	}
+	return strings.NewReplacer("/", "-", ":", "-", "~", "-", "^", "-").Replace(rev)
+}
+
+// entryPath maps a path in the extracted bundle back to its manifest entry by
+// removing the revision prefix
+func (mp *ManifestProcessor) entryPath(relPath string) string {
+	for _, source := range mp.sources {
+		if source.prefix == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(relPath, source.prefix+string(filepath.Separator)); ok {
+			return rest
+		}
+	}
+	return relPath
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+)
+
+// StageFiles copies the files of the enabled entries from every source into
+// memory, each source under its prefix
+func (mp *ManifestProcessor) StageFiles(ctx context.Context, manifest Manifest) (fs.FS, error) {
+	if len(mp.sources) == 0 {
+		worktree, root, err := OpenDirFS(mp.dir)
+		if err != nil {
+			return nil, err
+		}
+		mp.roots = append(mp.roots, root)
+		mp.sources = []fileSource{{fsys: worktree}}
+	}
+
+	// Package entries are expanded against the first source; files that are
+	// missing from other sources are skipped there
+	first := mp.sources[0].fsys
+	fileList := enabledFiles(manifest, func(dir string) ([]fs.DirEntry, error) {
+		return fs.ReadDir(first, filepath.ToSlash(dir))
+	})
+
+	mp.stagingNotes = nil
+	staged := newMemFS()
+	for _, source := range mp.sources {
+		for _, file := range fileList {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			name := filepath.ToSlash(file)
+			if !fs.ValidPath(name) {
+				return nil, fmt.Errorf("error staging %s: outside the project root", file)
+			}
+			stagedName := path.Join(source.prefix, name)
+			err := stageFile(staged, source.fsys, name, stagedName)
+			if errors.Is(err, fs.ErrNotExist) {
+				mp.logger.Info("File is missing from source", "file", file, "source", source.prefix)
+				mp.stagingNotes = append(mp.stagingNotes, ReportFile{Path: stagedName, Status: FileMissing, Reason: "not found"})
+				continue
+			}
+			if errors.Is(err, errNotRegular) {
+				mp.stagingNotes = append(mp.stagingNotes, ReportFile{Path: stagedName, Status: FileSkipped, Reason: "not a regular file"})
+				continue
+			}
+			if errors.Is(err, errEscapingLink) {
+				mp.logger.Info("Skipping symlink that leads outside the source", "file", file, "source", source.prefix)
+				mp.stagingNotes = append(mp.stagingNotes, ReportFile{Path: stagedName, Status: FileSkipped, Reason: "symlink outside the source"})
+				continue
+			}
+			if errors.Is(err, errFileTooLarge) {
+				mp.logger.Info("Skipping file too large to read from the source", "file", file, "source", source.prefix)
+				mp.stagingNotes = append(mp.stagingNotes, ReportFile{Path: stagedName, Status: FileSkipped, Reason: "too large to read from the source"})
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error staging %s: %w", file, err)
+			}
+			mp.logger.V(1).Info("Staged file", "file", stagedName)
+		}
+	}
+	return staged, nil
+}
+
+// errNotRegular is returned by stageFile for directories, symlinks and other
+// files that are not staged
+var errNotRegular = errors.New("not a regular file")
+
+// errEscapingLink is returned by stageFile for symlinks that the source
+// refuses to follow because they lead outside its root
+var errEscapingLink = errors.New("symlink leads outside the source")
+
+// stageFile copies the regular file name of fsys to stagedName
+func stageFile(staged *memFS, fsys fs.FS, name, stagedName string) error {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		if link, lerr := fs.Lstat(fsys, name); lerr == nil && link.Mode()&fs.ModeSymlink != 0 && !errors.Is(err, fs.ErrNotExist) {
+			return errEscapingLink
+		}
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errNotRegular
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	return staged.add(stagedName, data, info.Mode(), info.ModTime())
+}
+
+// enabledFiles lists the files of the enabled manifest entries, expanding
+// package entries with readDir
+func enabledFiles(manifest Manifest, readDir func(string) ([]fs.DirEntry, error)) []string {
+	var fileList []string
+	seen := make(map[string]bool)
+	add := func(files ...string) {
+		for _, file := range files {
+			if !seen[file] {
+				seen[file] = true
+				fileList = append(fileList, file)
+			}
+		}
+	}
+	for file, isCommented := range manifest.FileList {
+		if isCommented {
+			continue
+		}
+		// Package entries such as "core#ClipboardWriter" pull in the whole
+		// package; the symbols are picked out when rendering
+		if len(manifest.Symbols[file]) > 0 {
+			if entries, err := readDir(file); err == nil {
+				add(goPackageFiles(file, entries)...)
+				continue
+			}
+		}
+		add(file)
+	}
+	return fileList
+}
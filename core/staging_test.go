@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStageFiles(t *testing.T) {
+	project := fstest.MapFS{
+		"main.go":         {Data: []byte("package main\n")},
+		"core/a.go":       {Data: []byte("package core\n")},
+		"core/a_test.go":  {Data: []byte("package core\n")},
+		"core/b.go":       {Data: []byte("package core\n\nfunc B() {}\n")},
+		"docs/readme.txt": {Data: []byte("docs\n")},
+	}
+	old := fstest.MapFS{
+		"main.go": {Data: []byte("package main // old\n")},
+	}
+
+	tests := []struct {
+		name     string
+		sources  []fileSource
+		manifest Manifest
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			name:    "enabled files",
+			sources: []fileSource{{fsys: project}},
+			manifest: Manifest{FileList: map[string]bool{
+				"main.go":         false,
+				"docs/readme.txt": true,
+			}},
+			want: map[string]string{"main.go": "package main\n"},
+		},
+		{
+			name:    "package entry",
+			sources: []fileSource{{fsys: project}},
+			manifest: Manifest{
+				FileList: map[string]bool{"core": false},
+				Symbols:  map[string][]string{"core": {"B"}},
+			},
+			want: map[string]string{"core/a.go": "package core\n", "core/b.go": "package core\n\nfunc B() {}\n"},
+		},
+		{
+			name:     "prefixed sources skip missing files",
+			sources:  []fileSource{{prefix: "old", fsys: old}, {prefix: "new", fsys: project}},
+			manifest: Manifest{FileList: map[string]bool{"main.go": false, "core/a.go": false}},
+			want: map[string]string{
+				"old/main.go":   "package main // old\n",
+				"new/main.go":   "package main\n",
+				"new/core/a.go": "package core\n",
+			},
+		},
+		{
+			name:     "entry outside the root",
+			sources:  []fileSource{{fsys: project}},
+			manifest: Manifest{FileList: map[string]bool{"../secret.txt": false}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml")
+			mp.sources = tt.sources
+			staged, err := mp.StageFiles(context.Background(), tt.manifest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StageFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := make(map[string]string)
+			err = fs.WalkDir(staged, ".", func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				data, err := fs.ReadFile(staged, path)
+				got[path] = string(data)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WalkDir() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("staged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pairTestsSetting is the manifest setting that turns on test pairing
+	pairTestsSetting = "pair_tests"
+	// testPatternsSetting replaces the test file patterns, comma separated
+	testPatternsSetting = "test_patterns"
+)
+
+// defaultTestPatterns name test files after their source, with * standing for
+// the source file name without its extension
+var defaultTestPatterns = []string{"*_test.go", "test_*.py", "*_test.py", "*.spec.ts", "*.test.ts", "*.spec.js", "*.test.js"}
+
+const pairedCommentHeader = `nearwait: files listed as "paired" were added because test pairing is on; they are not enabled in the manifest.
+`
+
+// WithTestPairing makes Process pull in the sibling tests of enabled sources,
+// and the sources of enabled tests. Empty patterns keep the defaults.
+func (mp *ManifestProcessor) WithTestPairing(pair bool, patterns []string) *ManifestProcessor {
+	mp.pairTests = pair
+	mp.testPatterns = patterns
+	return mp
+}
+
+// testPairing combines the processor options with the manifest settings
+func (mp *ManifestProcessor) testPairing(manifest Manifest) (bool, []string) {
+	pair := mp.pairTests
+	if value, ok := manifest.Settings[pairTestsSetting]; ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			mp.logger.Info("Ignoring invalid manifest setting", "setting", pairTestsSetting, "value", value)
+		}
+		pair = pair || enabled
+	}
+
+	patterns := mp.testPatterns
+	if value := manifest.Settings[testPatternsSetting]; value != "" {
+		patterns = nil
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		patterns = defaultTestPatterns
+	}
+	return pair, patterns
+}
+
+// pairTestFiles enables the existing partner of every enabled file and
+// returns the files it enabled
+func (mp *ManifestProcessor) pairTestFiles(manifest Manifest) []string {
+	pair, patterns := mp.testPairing(manifest)
+	if !pair {
+		return nil
+	}
+
+	var enabled []string
+	for file, isCommented := range manifest.FileList {
+		if !isCommented {
+			enabled = append(enabled, file)
+		}
+	}
+
+	var paired []string
+	for _, file := range enabled {
+		for _, partner := range testPartners(filepath.Base(file), patterns) {
+			path := filepath.Join(filepath.Dir(file), partner)
+			if isCommented, listed := manifest.FileList[path]; listed && !isCommented {
+				continue
+			}
+			if info, err := mp.statEntry(path); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			manifest.FileList[path] = false
+			paired = append(paired, path)
+		}
+	}
+
+	sort.Strings(paired)
+	return paired
+}
+
+// testPartners returns the names a test for base, or the source of test base,
+// would have under patterns
+func testPartners(base string, patterns []string) []string {
+	var partners []string
+	for _, pattern := range patterns {
+		prefix, suffix, ok := strings.Cut(pattern, "*")
+		if ok && len(base) > len(prefix)+len(suffix) && strings.HasPrefix(base, prefix) && strings.HasSuffix(base, suffix) {
+			stem := base[len(prefix) : len(base)-len(suffix)]
+			return []string{stem + filepath.Ext(pattern)}
+		}
+	}
+
+	for _, pattern := range patterns {
+		prefix, suffix, ok := strings.Cut(pattern, "*")
+		ext := filepath.Ext(pattern)
+		if ok && ext != "" && filepath.Ext(base) == ext {
+			partners = append(partners, prefix+strings.TrimSuffix(base, ext)+suffix)
+		}
+	}
+	return partners
+}
+
+// pairedComment lists the paired files for the archive comment
+func (mp *ManifestProcessor) pairedComment() []byte {
+	if len(mp.pairedTests) == 0 {
+		return nil
+	}
+	var comment strings.Builder
+	comment.WriteString(pairedCommentHeader)
+	for _, path := range mp.pairedTests {
+		comment.WriteString("paired: " + filepath.ToSlash(path) + "\n")
+	}
+	return []byte(comment.String())
+}
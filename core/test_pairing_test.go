@@ -0,0 +1,114 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTestPartners(t *testing.T) {
+	tests := []struct {
+		base string
+		want []string
+	}{
+		{base: "batch_utils.go", want: []string{"batch_utils_test.go"}},
+		{base: "batch_utils_test.go", want: []string{"batch_utils.go"}},
+		{base: "models.py", want: []string{"test_models.py", "models_test.py"}},
+		{base: "test_models.py", want: []string{"models.py"}},
+		{base: "app.spec.ts", want: []string{"app.ts"}},
+		{base: "app.ts", want: []string{"app.spec.ts", "app.test.ts"}},
+		{base: "README.md", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base, func(t *testing.T) {
+			if got := testPartners(tt.base, defaultTestPatterns); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("testPartners(%q) = %v, want %v", tt.base, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPairTestFiles(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"core/batch.go":      "package core\n",
+		"core/batch_test.go": "package core\n",
+		"core/lonely.go":     "package core\n",
+		"web/app.ts":         "export {}\n",
+		"web/app.spec.ts":    "test()\n",
+	})
+	t.Chdir(dir)
+
+	tests := []struct {
+		name     string
+		pair     bool
+		patterns []string
+		settings map[string]string
+		want     []string
+	}{
+		{name: "off", want: nil},
+		{name: "flag", pair: true, want: []string{filepath.Join("core", "batch_test.go"), filepath.Join("web", "app.spec.ts")}},
+		{name: "setting", settings: map[string]string{pairTestsSetting: "true"}, want: []string{filepath.Join("core", "batch_test.go"), filepath.Join("web", "app.spec.ts")}},
+		{name: "patterns", pair: true, patterns: []string{"*_test.go"}, want: []string{filepath.Join("core", "batch_test.go")}},
+		{
+			name:     "pattern setting",
+			settings: map[string]string{pairTestsSetting: "true", testPatternsSetting: "*.spec.ts"},
+			want:     []string{filepath.Join("web", "app.spec.ts")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := Manifest{
+				FileList: map[string]bool{
+					filepath.Join("core", "batch.go"):      false,
+					filepath.Join("core", "batch_test.go"): true,
+					filepath.Join("core", "lonely.go"):     false,
+					filepath.Join("web", "app.ts"):         false,
+				},
+				Settings: tt.settings,
+			}
+			mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml").WithTestPairing(tt.pair, tt.patterns)
+
+			got := mp.pairTestFiles(manifest)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("pairTestFiles() = %v, want %v", got, tt.want)
+			}
+			for _, path := range got {
+				if manifest.FileList[path] {
+					t.Errorf("paired file %s is still commented out", path)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestSettingsRoundTrip(t *testing.T) {
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	content := "pair_tests: true\ntest_patterns: test_*.py, *.spec.ts\nfilelist:\n- main.go\n"
+	if err := os.WriteFile(manifestFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	mg := NewManifestGenerator(testLogger(t))
+	manifest, err := mg.ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	if manifest.Settings[pairTestsSetting] != "true" || manifest.Settings[testPatternsSetting] != "test_*.py, *.spec.ts" {
+		t.Errorf("ReadManifest() settings = %v", manifest.Settings)
+	}
+
+	if err := mg.WriteManifest(manifest, manifestFile); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+	written, err := os.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if !strings.HasPrefix(string(written), content) {
+		t.Errorf("WriteManifest() =\n%s\nwant\n%s", written, content)
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// traceFrame matches the "file.go:123" positions of goroutine dumps, such as
+// "\t/src/app/core/batch.go:42 +0x1d", and of go test failure messages
+var traceFrame = regexp.MustCompile(`((?:[A-Za-z]:)?[^\s:()"'<>]+\.go):(\d+)`)
+
+// ParseTrace extracts the Go source positions of a panic, goroutine dump or
+// test log, in order of appearance
+func ParseTrace(r io.Reader) ([]SourceLocation, error) {
+	var locs []SourceLocation
+	seen := make(map[SourceLocation]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		for _, m := range traceFrame.FindAllStringSubmatch(scanner.Text(), -1) {
+			line, err := strconv.Atoi(m[2])
+			if err != nil || line < 1 {
+				continue
+			}
+			loc := SourceLocation{Path: m[1], Lines: LineRange{Start: line, End: line}}
+			if !seen[loc] {
+				seen[loc] = true
+				locs = append(locs, loc)
+			}
+		}
+	}
+	return locs, scanner.Err()
+}
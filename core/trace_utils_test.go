@@ -0,0 +1,159 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleTrace = `--- FAIL: TestBoom (0.00s)
+    batch_test.go:42: unexpected batch count
+panic: assignment to entry in nil map [recovered]
+
+goroutine 7 [running]:
+testing.tRunner.func1.2({0x5140c0, 0x585f90})
+	/usr/local/go/src/testing/testing.go:2123 +0x232
+example.com/app/core.Boom(...)
+	PROJECT/core/batch.go:5
+example.com/app/core.TestBoom(0xc000003340?)
+	PROJECT/core/batch_test.go:6 +0x29
+github.com/spf13/cobra.(*Command).execute(0xc0000f2308)
+	GOMODCACHE/github.com/spf13/cobra@v1.10.2/command.go:1015 +0xb02
+created by testing.(*T).Run in goroutine 1
+	/elsewhere/other/batch.go:9 +0x4d4
+`
+
+func TestParseTrace(t *testing.T) {
+	locs, err := ParseTrace(strings.NewReader(sampleTrace))
+	if err != nil {
+		t.Fatalf("ParseTrace() error = %v", err)
+	}
+
+	want := []string{
+		"batch_test.go:42",
+		"/usr/local/go/src/testing/testing.go:2123",
+		"PROJECT/core/batch.go:5",
+		"PROJECT/core/batch_test.go:6",
+		"GOMODCACHE/github.com/spf13/cobra@v1.10.2/command.go:1015",
+		"/elsewhere/other/batch.go:9",
+	}
+	var got []string
+	for _, loc := range locs {
+		got = append(got, loc.Path+":"+loc.Lines.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTrace() = %v, want %v", got, want)
+	}
+}
+
+func TestResolveLocations(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"core/batch.go":      "package core\n",
+		"core/batch_test.go": "package core\n",
+		"web/batch.go":       "package web\n",
+	})
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := t.TempDir()
+	t.Setenv("GOMODCACHE", cache)
+
+	trace := strings.NewReplacer("PROJECT", dir, "GOMODCACHE", cache).Replace(sampleTrace)
+	locs, err := ParseTrace(strings.NewReader(trace))
+	if err != nil {
+		t.Fatalf("ParseTrace() error = %v", err)
+	}
+
+	mg := NewManifestGenerator(testLogger(t)).WithFS(os.DirFS(dir)).WithDir(dir)
+	resolved, err := mg.ResolveLocations(locs)
+	if err != nil {
+		t.Fatalf("ResolveLocations() error = %v", err)
+	}
+
+	batchTest := filepath.Join("core", "batch_test.go")
+	want := []SourceLocation{
+		{Path: batchTest, Lines: LineRange{Start: 42, End: 42}},
+		{Path: filepath.Join("core", "batch.go"), Lines: LineRange{Start: 5, End: 5}},
+		{Path: batchTest, Lines: LineRange{Start: 6, End: 6}},
+	}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Fatalf("ResolveLocations() = %v, want %v", resolved, want)
+	}
+
+	tests := []struct {
+		name    string
+		context int
+		want    map[string][]LineRange
+	}{
+		{
+			name:    "whole files",
+			context: -1,
+			want:    map[string][]LineRange{batchTest: nil, filepath.Join("core", "batch.go"): nil},
+		},
+		{
+			name:    "context",
+			context: 2,
+			want: map[string][]LineRange{
+				batchTest:                         {{Start: 4, End: 8}, {Start: 40, End: 44}},
+				filepath.Join("core", "batch.go"): {{Start: 3, End: 7}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LocationRanges(resolved, tt.context); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LocationRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnableFileRanges(t *testing.T) {
+	mg := NewManifestGenerator(testLogger(t))
+	mockWriter := &MockManifestWriter{ManifestData: Manifest{
+		FileList: map[string]bool{
+			"full.go":    false,
+			"ranged.go":  false,
+			"stale.go":   true,
+			"whole.go":   false,
+			"comment.go": true,
+		},
+		Ranges: map[string][]LineRange{
+			"ranged.go": {{Start: 1, End: 3}},
+			"stale.go":  {{Start: 50, End: 60}},
+			"whole.go":  {{Start: 1, End: 2}},
+		},
+	}}
+	mg.reader = mockWriter
+	mg.writer = mockWriter
+
+	enabled, err := mg.EnableFileRanges(".nearwait.yml", map[string][]LineRange{
+		"full.go":   {{Start: 5, End: 6}},
+		"ranged.go": {{Start: 4, End: 8}},
+		"stale.go":  {{Start: 1, End: 2}},
+		"whole.go":  nil,
+		"new.go":    {{Start: 10, End: 12}},
+	})
+	if err != nil {
+		t.Fatalf("EnableFileRanges() error = %v", err)
+	}
+	if enabled != 2 {
+		t.Errorf("EnableFileRanges() enabled %d files, want 2", enabled)
+	}
+
+	want := "filelist:\n# - comment.go\n- full.go\n- new.go\n- ranged.go\n- stale.go\n- whole.go\n"
+	if mockWriter.ManifestContent != want {
+		t.Errorf("Manifest = %q, want %q", mockWriter.ManifestContent, want)
+	}
+	wantRanges := map[string][]LineRange{
+		"new.go":    {{Start: 10, End: 12}},
+		"ranged.go": {{Start: 1, End: 8}},
+		"stale.go":  {{Start: 1, End: 2}},
+	}
+	if !reflect.DeepEqual(mockWriter.ManifestData.Ranges, wantRanges) {
+		t.Errorf("Ranges = %v, want %v", mockWriter.ManifestData.Ranges, wantRanges)
+	}
+}
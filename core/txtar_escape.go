@@ -0,0 +1,159 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/txtar"
+)
+
+// quotePrefix is prepended to every line of a file that contains txtar markers
+const quotePrefix = ">"
+
+// escapedNamePrefix names sections whose original name cannot appear in a txtar header
+const escapedNamePrefix = "nearwait-escaped-"
+
+const escapeCommentHeader = `nearwait: some files were escaped so this archive parses back into the original tree.
+Files listed as "quoted" contain txtar file markers; remove one leading ">" from every line to restore them.
+Files listed as "encoded" have names that cannot appear in a txtar header; they are stored base64-encoded
+under a placeholder name, followed by their original name as a Go string literal.
+`
+
+// hasMarker reports whether any line of data would be parsed as a txtar file marker
+func hasMarker(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) >= len("-- ")+len(" --") && bytes.HasPrefix(line, []byte("-- ")) && bytes.HasSuffix(line, []byte(" --")) {
+			return true
+		}
+	}
+	return false
+}
+
+// validSectionName reports whether name survives a round trip through a txtar header
+func validSectionName(name string) bool {
+	return name != "" && name == strings.TrimSpace(name) && !strings.ContainsAny(name, "\r\n")
+}
+
+func quoteData(data []byte) []byte {
+	lines := strings.SplitAfter(string(data), "\n")
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		buf.WriteString(quotePrefix + line)
+	}
+	return buf.Bytes()
+}
+
+func unquoteData(data []byte) []byte {
+	lines := strings.SplitAfter(string(data), "\n")
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(strings.TrimPrefix(line, quotePrefix))
+	}
+	return buf.Bytes()
+}
+
+// escapeArchive rewrites every section of ar that would not parse back into
+// the same file, and documents the escaping in the archive comment
+func escapeArchive(ar *txtar.Archive) {
+	var notes []string
+	for i, f := range ar.Files {
+		switch {
+		case !validSectionName(f.Name):
+			// Quoting cannot help a name that breaks the header, so fall back to
+			// an encoded section under a placeholder name
+			placeholder := fmt.Sprintf("%s%d", escapedNamePrefix, len(notes)+1)
+			notes = append(notes, fmt.Sprintf("encoded: %s %s", placeholder, strconv.Quote(f.Name)))
+			ar.Files[i] = txtar.File{Name: placeholder, Data: renderBinary(f.Name, f.Data, BinaryBase64)}
+		case hasMarker(f.Data):
+			notes = append(notes, "quoted: "+f.Name)
+			ar.Files[i].Data = quoteData(f.Data)
+		}
+	}
+	if len(notes) == 0 {
+		return
+	}
+
+	var comment bytes.Buffer
+	comment.Write(ar.Comment)
+	if comment.Len() > 0 {
+		comment.WriteString("\n")
+	}
+	comment.WriteString(escapeCommentHeader)
+	for _, note := range notes {
+		comment.WriteString(note + "\n")
+	}
+	ar.Comment = comment.Bytes()
+}
+
+// escapedSize returns the number of bytes f occupies in an archive of up to
+// sections files once escaped: its header and data, and the line it adds to
+// the escape note in the archive comment, without the note header
+func escapedSize(f txtar.File, sections int) (size, note int64) {
+	ar := txtar.Archive{Files: []txtar.File{f}}
+	escapeArchive(&ar)
+	escaped := ar.Files[0]
+	// The trailing newline txtar adds to data without one is always counted
+	size = int64(len("-- "+escaped.Name+" --\n") + len(escaped.Data) + 1)
+	if len(ar.Comment) > 0 {
+		note = int64(len(ar.Comment) - len(escapeCommentHeader))
+	}
+	if escaped.Name != f.Name {
+		// Placeholders are numbered within the archive, so allow for the
+		// widest number in both the header and the note
+		pad := int64(len(strconv.Itoa(sections)) - 1)
+		size += pad
+		note += pad
+	}
+	return size, note
+}
+
+// UnescapeArchive reverses the escaping nearwait applies to archive sections,
+// as documented in the archive comment, so ar holds the original files again
+func UnescapeArchive(ar *txtar.Archive) error {
+	quoted := make(map[string]bool)
+	encoded := make(map[string]string)
+
+	for _, line := range strings.Split(string(ar.Comment), "\n") {
+		switch {
+		case strings.HasPrefix(line, "quoted: "):
+			quoted[strings.TrimPrefix(line, "quoted: ")] = true
+		case strings.HasPrefix(line, "encoded: "):
+			placeholder, literal, ok := strings.Cut(strings.TrimPrefix(line, "encoded: "), " ")
+			if !ok {
+				return fmt.Errorf("malformed escape note %q", line)
+			}
+			name, err := strconv.Unquote(literal)
+			if err != nil {
+				return fmt.Errorf("malformed escape note %q: %w", line, err)
+			}
+			encoded[placeholder] = name
+		}
+	}
+
+	for i, f := range ar.Files {
+		if quoted[f.Name] {
+			ar.Files[i].Data = unquoteData(f.Data)
+			continue
+		}
+		name, ok := encoded[f.Name]
+		if !ok {
+			continue
+		}
+		// Drop the marker line written by renderBinary before decoding
+		_, body, _ := bytes.Cut(f.Data, []byte("\n"))
+		data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(body), "\n", ""))
+		if err != nil {
+			return fmt.Errorf("error decoding %s: %w", f.Name, err)
+		}
+		ar.Files[i] = txtar.File{Name: name, Data: data}
+	}
+
+	return nil
+}
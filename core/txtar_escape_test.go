@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/tools/txtar"
+)
+
+func TestHasMarker(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"plain text\n", false},
+		{"-- foo.go --\n", true},
+		{"prefix\n-- foo.go --\r\nsuffix", true},
+		{"-- not a marker\n", false},
+		{"--  --\n", true},
+		{"x -- foo.go --\n", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasMarker([]byte(tt.data)); got != tt.want {
+			t.Errorf("hasMarker(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeArchiveRoundTrip(t *testing.T) {
+	nested, err := os.ReadFile(filepath.Join("testdata", "escape", "nested.txtar"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	want := []txtar.File{
+		{Name: "README.md", Data: []byte("plain file\n")},
+		{Name: "testdata/nested.txtar", Data: nested},
+		{Name: "quoted.txt", Data: []byte(">already quoted\n-- x --\n>>twice\n")},
+		{Name: " padded name\n", Data: []byte("-- y --\n")},
+	}
+
+	ar := txtar.Archive{Files: append([]txtar.File(nil), want...)}
+	escapeArchive(&ar)
+
+	parsed := txtar.Parse(txtar.Format(&ar))
+	if len(parsed.Files) != len(want) {
+		t.Fatalf("Escaped archive parsed into %d files, want %d", len(parsed.Files), len(want))
+	}
+
+	if err := UnescapeArchive(parsed); err != nil {
+		t.Fatalf("UnescapeArchive() error = %v", err)
+	}
+
+	for i, f := range parsed.Files {
+		if f.Name != want[i].Name {
+			t.Errorf("File %d name = %q, want %q", i, f.Name, want[i].Name)
+		}
+		if !bytes.Equal(f.Data, want[i].Data) {
+			t.Errorf("File %q data = %q, want %q", want[i].Name, f.Data, want[i].Data)
+		}
+	}
+}
+
+func TestCreateArchivesEscapeNestedTxtar(t *testing.T) {
+	nested, err := os.ReadFile(filepath.Join("testdata", "escape", "nested.txtar"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	files := map[string][]byte{
+		"main.go":               []byte("package main\n"),
+		"testdata/nested.txtar": nested,
+	}
+	staged := fstest.MapFS{}
+	for name, data := range files {
+		staged[name] = &fstest.MapFile{Data: data, Mode: 0o644}
+	}
+
+	mp := NewManifestProcessor(testLogger(t), false, ".nearwait.yml")
+	mp.WithBatchKBytes(1)
+
+	single, err := mp.createTxtarArchive(context.Background(), staged)
+	if err != nil {
+		t.Fatalf("createTxtarArchive() error = %v", err)
+	}
+	archives := [][]byte{single}
+
+	batches, err := mp.createBatches(context.Background(), staged)
+	if err != nil {
+		t.Fatalf("createBatches() error = %v", err)
+	}
+	archives = append(archives, batches...)
+
+	for i, data := range archives {
+		ar := txtar.Parse(data)
+		if err := UnescapeArchive(ar); err != nil {
+			t.Fatalf("UnescapeArchive() error = %v", err)
+		}
+		if i == 0 && len(ar.Files) != len(files) {
+			t.Errorf("Single archive contains %d files, want %d", len(ar.Files), len(files))
+		}
+		for _, f := range ar.Files {
+			want, ok := files[f.Name]
+			if !ok {
+				t.Errorf("Archive %d contains unexpected file %q", i, f.Name)
+				continue
+			}
+			if !bytes.Equal(f.Data, want) {
+				t.Errorf("Archive %d file %q = %q, want %q", i, f.Name, f.Data, want)
+			}
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package core
 
 import (
+	"context"
 	"fmt"
-	"os"
 )
 
-func (mp *ManifestProcessor) ProcessTxtarArchive(manifest Manifest, projectInfo ProjectInfo) error {
+// ProcessTxtarArchive renders the staged files into a txtar archive, writes
+// it to the output sink and returns it
+func (mp *ManifestProcessor) ProcessTxtarArchive(ctx context.Context, manifest Manifest, projectInfo ProjectInfo) ([]byte, error) {
 	var uncommentedFiles []string
 	for file, isCommented := range manifest.FileList {
 		if !isCommented {
@@ -14,23 +16,23 @@ func (mp *ManifestProcessor) ProcessTxtarArchive(manifest Manifest, projectInfo
 	}
 
 	if len(uncommentedFiles) == 0 {
-		if err := os.Remove(projectInfo.TxtarFile); err != nil && !os.IsNotExist(err) {
-			return fmt.Errorf("error deleting empty txtar archive: %w", err)
+		if err := mp.output.Remove(projectInfo.TxtarFile); err != nil {
+			return nil, fmt.Errorf("error deleting empty txtar archive: %w", err)
 		}
 		mp.logger.V(1).Info("No uncommented files, txtar archive not created or deleted if existed")
-		return nil
+		return nil, nil
 	}
 
-	txtarContent, err := mp.createTxtarArchive(projectInfo.ExtractDir)
+	txtarContent, err := mp.createTxtarArchive(ctx, projectInfo.Files)
 	if err != nil {
-		return fmt.Errorf("error creating txtar archive: %w", err)
+		return nil, fmt.Errorf("error creating txtar archive: %w", err)
 	}
 
-	if err := os.WriteFile(projectInfo.TxtarFile, txtarContent, 0o644); err != nil {
-		return fmt.Errorf("error writing txtar archive: %w", err)
+	if err := mp.output.WriteFile(projectInfo.TxtarFile, txtarContent); err != nil {
+		return nil, fmt.Errorf("error writing txtar archive: %w", err)
 	}
 
 	mp.logger.V(1).Info("Created txtar archive", "path", projectInfo.TxtarFile)
 
-	return nil
+	return txtarContent, nil
 }
@@ -1,51 +1,27 @@
 package core
 
 import (
-	"os"
-	"path/filepath"
+	"context"
+	"io/fs"
 
 	"golang.org/x/tools/txtar"
 )
 
-func (mp *ManifestProcessor) createTxtarArchive(extractDir string) ([]byte, error) {
-	mp.logger.V(1).Info("Creating txtar archive", "from", extractDir)
+func (mp *ManifestProcessor) createTxtarArchive(ctx context.Context, staged fs.FS) ([]byte, error) {
+	mp.logger.V(1).Info("Creating txtar archive")
 
-	var ar txtar.Archive
-
-	err := filepath.Walk(extractDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			return nil
-		}
-
-		// Skip the .tar file
-		if filepath.Ext(path) == ".tar" {
-			return nil
-		}
-
-		relPath, err := filepath.Rel(extractDir, path)
-		if err != nil {
-			return err
-		}
-
-		mp.logger.V(1).Info("Adding file to txtar", "file", relPath)
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
-
-		ar.Files = append(ar.Files, txtar.File{
-			Name: relPath,
-			Data: content,
-		})
-		return nil
-	})
+	files, err := mp.renderFiles(ctx, staged)
 	if err != nil {
 		return nil, err
 	}
 
+	ar := txtar.Archive{Comment: mp.pairedComment()}
+	for _, file := range files {
+		mp.logger.V(1).Info("Adding file to txtar", "file", file.Name)
+		ar.Files = append(ar.Files, file)
+	}
+
+	escapeArchive(&ar)
+
 	return txtar.Format(&ar), nil
 }
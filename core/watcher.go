@@ -0,0 +1,342 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"github.com/mitchellh/go-homedir"
+)
+
+// Watcher keeps the manifest and txtar bundle up to date as project files change
+type Watcher struct {
+	logger       logr.Logger
+	generator    *ManifestGenerator
+	processor    *ManifestProcessor
+	manifestFile string
+	root         string
+	// mounts maps the name of each mount in the manifest to its directory
+	mounts       map[string]string
+	watched      map[string]bool
+	debounce     time.Duration
+	out          io.Writer
+	manifest     Manifest
+	manifestData []byte
+}
+
+// refreshRequest accumulates the work requested by a burst of events
+type refreshRequest struct {
+	generate bool
+	process  bool
+	changes  int
+}
+
+// NewWatcher watches the project directory of processor, the working
+// directory when processor is nil, and the directories mounted by the manifest
+func NewWatcher(logger logr.Logger, generator *ManifestGenerator, processor *ManifestProcessor, manifestFile string) *Watcher {
+	root := "."
+	if processor != nil {
+		root = processor.dir
+	}
+	return &Watcher{
+		logger:       logger,
+		generator:    generator,
+		processor:    processor,
+		manifestFile: manifestFile,
+		root:         absPath(root),
+		watched:      make(map[string]bool),
+		debounce:     300 * time.Millisecond,
+		out:          os.Stdout,
+		manifest:     Manifest{FileList: make(map[string]bool)},
+	}
+}
+
+// WithDebounce sets how long to wait for a burst of events to settle before refreshing
+func (w *Watcher) WithDebounce(debounce time.Duration) *Watcher {
+	w.debounce = debounce
+	return w
+}
+
+// WithOutput sets where the one-line refresh summaries are written
+func (w *Watcher) WithOutput(out io.Writer) *Watcher {
+	w.out = out
+	return w
+}
+
+// Run generates and processes the manifest once, then refreshes it on every
+// change under the project root or a mounted directory until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %w", err)
+	}
+	defer fsw.Close()
+
+	if err := w.addDirs(fsw, w.root); err != nil {
+		return fmt.Errorf("error watching project root: %w", err)
+	}
+
+	w.refresh(refreshRequest{generate: true, process: true})
+	w.watchMounts(fsw)
+
+	var pending refreshRequest
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			rel, ok := w.relPath(event.Name)
+			if !ok && !w.isOwnFile(event.Name) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := w.addDirs(fsw, event.Name); err != nil {
+						w.logger.Error(err, "Failed to watch new directory", "path", rel)
+					}
+				}
+			}
+			generate, process := w.classify(event.Name, event.Op)
+			if !generate && !process {
+				continue
+			}
+			w.logger.V(1).Info("Detected change", "path", rel, "op", event.Op.String())
+			pending.generate = pending.generate || generate
+			pending.process = pending.process || process
+			pending.changes++
+			timer.Reset(w.debounce)
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.Error(err, "File watcher error")
+
+		case <-timer.C:
+			w.refresh(pending)
+			w.watchMounts(fsw)
+			pending = refreshRequest{}
+		}
+	}
+}
+
+// addDirs registers dir and every non-excluded directory below it with the watcher
+func (w *Watcher) addDirs(fsw *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if rel, _ := w.relPath(path); w.skipDir(rel) {
+			return filepath.SkipDir
+		}
+		w.logger.V(1).Info("Watching directory", "path", path)
+		return fsw.Add(path)
+	})
+}
+
+func (w *Watcher) skipDir(rel string) bool {
+	if rel == "." || !w.generator.isExcluded(rel) {
+		return false
+	}
+	// An include such as core/sub excludes its parent directory core, which
+	// still has to be watched to see changes below it.
+	for include := range w.generator.includeDirs {
+		if strings.HasPrefix(include, rel+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return true
+}
+
+// watchMounts starts watching the directories mounted by the manifest that
+// are not watched yet
+func (w *Watcher) watchMounts(fsw *fsnotify.Watcher) {
+	w.mounts = make(map[string]string)
+	value, ok := w.manifest.Settings[mountsSetting]
+	if !ok {
+		return
+	}
+	mounts, err := ParseMounts(value)
+	if err != nil {
+		// Build reports the broken setting
+		return
+	}
+	for name, dir := range mounts {
+		expanded, err := homedir.Expand(dir)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(w.root, expanded)
+		}
+		expanded = filepath.Clean(expanded)
+		w.mounts[name] = expanded
+		if w.watched[expanded] {
+			continue
+		}
+		if err := w.addDirs(fsw, expanded); err != nil {
+			w.logger.Error(err, "Failed to watch mounted directory", "mount", name, "path", dir)
+			continue
+		}
+		w.watched[expanded] = true
+	}
+}
+
+// relPath turns a watched path into the name of its manifest entry, such as
+// shared/lib.go for lib.go in the directory mounted as shared. Paths outside
+// the project root and its mounts are not entries.
+func (w *Watcher) relPath(path string) (string, bool) {
+	path = absPath(path)
+	for name, dir := range w.mounts {
+		if rel, ok := pathBelow(dir, path); ok {
+			return filepath.Join(name, rel), true
+		}
+	}
+	return pathBelow(w.root, path)
+}
+
+// pathBelow returns path relative to dir when it lies below dir
+func pathBelow(dir, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// isOwnFile reports whether path is the manifest or its txtar archive
+func (w *Watcher) isOwnFile(path string) bool {
+	path = absPath(path)
+	return path == absPath(w.manifestFile) || path == absPath(txtarPathFor(w.manifestFile))
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// classify decides whether a change to path requires regenerating the
+// manifest, re-rendering the bundle, or both
+func (w *Watcher) classify(path string, op fsnotify.Op) (generate, process bool) {
+	switch absPath(path) {
+	case absPath(w.manifestFile):
+		// Generate rewrites the manifest, so only react to edits that changed it
+		data, err := os.ReadFile(w.manifestFile)
+		return false, err != nil || !bytes.Equal(data, w.manifestData)
+	case absPath(txtarPathFor(w.manifestFile)):
+		return false, false
+	}
+
+	rel, ok := w.relPath(path)
+	if !ok || w.generator.isExcluded(rel) {
+		return false, false
+	}
+
+	isCommented, listed := w.manifest.FileList[rel]
+	enabled := listed && !isCommented
+
+	switch {
+	case op.Has(fsnotify.Create):
+		return true, false
+	case op.Has(fsnotify.Remove), op.Has(fsnotify.Rename):
+		return true, enabled
+	case op.Has(fsnotify.Write):
+		return false, enabled
+	}
+	return false, false
+}
+
+// refresh performs the requested work and prints a one-line summary
+func (w *Watcher) refresh(req refreshRequest) {
+	start := time.Now()
+	var parts []string
+
+	if req.generate {
+		if _, err := w.generator.Generate(false, w.manifestFile); err != nil {
+			w.summarize(start, req, fmt.Sprintf("failed to generate manifest: %v", err))
+			return
+		}
+		parts = append(parts, "manifest updated")
+	}
+
+	if err := w.loadManifest(); err != nil {
+		w.summarize(start, req, fmt.Sprintf("failed to read manifest: %v", err))
+		return
+	}
+
+	if req.process {
+		isEmpty, err := w.processor.Process()
+		switch {
+		case err != nil:
+			parts = append(parts, fmt.Sprintf("failed to process manifest: %v", err))
+		case isEmpty:
+			parts = append(parts, "manifest file list is empty")
+		default:
+			parts = append(parts, w.bundleSummary())
+		}
+	}
+
+	w.summarize(start, req, strings.Join(parts, ", "))
+}
+
+func (w *Watcher) loadManifest() error {
+	data, err := os.ReadFile(w.manifestFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	manifest, err := w.generator.ReadManifest(w.manifestFile)
+	if err != nil {
+		return err
+	}
+	w.manifestData = data
+	w.manifest = manifest
+	return nil
+}
+
+func (w *Watcher) bundleSummary() string {
+	enabled := 0
+	for _, isCommented := range w.manifest.FileList {
+		if !isCommented {
+			enabled++
+		}
+	}
+	size := int64(0)
+	if info, err := os.Stat(txtarPathFor(w.manifestFile)); err == nil {
+		size = info.Size()
+	}
+	return fmt.Sprintf("bundled %d files (%.1f KB)", enabled, float64(size)/1024)
+}
+
+func (w *Watcher) summarize(start time.Time, req refreshRequest, summary string) {
+	if summary == "" {
+		summary = "nothing to do"
+	}
+	trigger := "startup"
+	if req.changes > 0 {
+		trigger = fmt.Sprintf("%d changes", req.changes)
+	}
+	fmt.Fprintf(w.out, "%s [%s] %s in %s\n",
+		time.Now().Format(time.Kitchen), trigger, summary, time.Since(start).Round(time.Millisecond))
+}
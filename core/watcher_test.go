@@ -0,0 +1,125 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatcherClassify(t *testing.T) {
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	project := t.TempDir()
+	shared := t.TempDir()
+	processor := NewManifestProcessor(testLogger(t), false, manifestFile).WithDir(project)
+	w := NewWatcher(testLogger(t), NewManifestGenerator(testLogger(t)), processor, manifestFile)
+	w.manifest = Manifest{FileList: map[string]bool{
+		"main.go":                         false,
+		"README.md":                       true,
+		filepath.Join("shared", "lib.go"): false,
+	}}
+	w.mounts = map[string]string{"shared": shared}
+
+	tests := []struct {
+		name         string
+		path         string
+		op           fsnotify.Op
+		wantGenerate bool
+		wantProcess  bool
+	}{
+		{"Write to enabled file", filepath.Join(project, "main.go"), fsnotify.Write, false, true},
+		{"Write to commented file", filepath.Join(project, "README.md"), fsnotify.Write, false, false},
+		{"New file", filepath.Join(project, "new.go"), fsnotify.Create, true, false},
+		{"Removed enabled file", filepath.Join(project, "main.go"), fsnotify.Remove, true, true},
+		{"Renamed commented file", filepath.Join(project, "README.md"), fsnotify.Rename, true, false},
+		{"Excluded directory", filepath.Join(project, "node_modules/pkg/index.js"), fsnotify.Create, false, false},
+		{"Chmod only", filepath.Join(project, "main.go"), fsnotify.Chmod, false, false},
+		{"Write to enabled mounted file", filepath.Join(shared, "lib.go"), fsnotify.Write, false, true},
+		{"New mounted file", filepath.Join(shared, "util.go"), fsnotify.Create, true, false},
+		{"Outside the project", filepath.Join(filepath.Dir(project), "other.go"), fsnotify.Create, false, false},
+		{"Txtar output", txtarPathFor(manifestFile), fsnotify.Write, false, false},
+		{"Missing manifest", manifestFile, fsnotify.Write, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generate, process := w.classify(tt.path, tt.op)
+			if generate != tt.wantGenerate || process != tt.wantProcess {
+				t.Errorf("classify(%q, %v) = (%v, %v), want (%v, %v)",
+					tt.path, tt.op, generate, process, tt.wantGenerate, tt.wantProcess)
+			}
+		})
+	}
+}
+
+// syncBuffer collects the summaries written by a running watcher
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatcherMounts(t *testing.T) {
+	root := writeTestModule(t, map[string]string{
+		"proj/main.go":      "package main\n",
+		"shared-lib/lib.go": "package shared\n",
+	})
+	proj := filepath.Join(root, "proj")
+	manifestFile := filepath.Join(proj, ".nearwait.yml")
+	manifest := "mounts: {shared: ../shared-lib}\nfilelist:\n- main.go\n- shared/lib.go\n"
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	generator := NewManifestGenerator(testLogger(t)).WithFS(os.DirFS(proj)).WithDir(proj)
+	processor := NewManifestProcessor(testLogger(t), false, manifestFile).
+		WithDir(proj).
+		WithOutput(NewMemorySink()).
+		WithNoopClipboard()
+	var out syncBuffer
+	w := NewWatcher(testLogger(t), generator, processor, manifestFile).
+		WithDebounce(10 * time.Millisecond).
+		WithOutput(&out)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	}()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(out.String(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("no %q in watcher output:\n%s", want, out.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("[startup]")
+	if err := os.WriteFile(filepath.Join(root, "shared-lib", "lib.go"), []byte("package shared // edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("[1 changes] bundled")
+}
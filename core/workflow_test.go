@@ -2,10 +2,10 @@ package core
 
 import (
 	"bytes"
-	"os"
-	"path/filepath"
+	"reflect"
 	"sort"
 	"testing"
+	"testing/fstest"
 
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap/zaptest"
@@ -63,131 +63,30 @@ func (m *MockClipboard) ShouldDelay() bool {
 	return false // Tests should not have delays
 }
 
-// MockFileSystemWalker implements FileSystemWalker for testing
-type MockFileSystemWalker struct {
-	Files map[string]bool
-}
-
-func (m *MockFileSystemWalker) GetCurrentFiles() (map[string]bool, error) {
-	return m.Files, nil
-}
-
-// MockArchiveProcessor implements ArchiveProcessor for testing
-type MockArchiveProcessor struct {
-	TxtarContent []byte
-}
-
-func (m *MockArchiveProcessor) ProcessTarArchive(manifest Manifest, projectInfo ProjectInfo) error {
-	// Create the extraction directory
-	if err := os.MkdirAll(projectInfo.ExtractDir, 0o755); err != nil {
-		return err
-	}
-
-	// Add some content to the extract directory so we'll have something in the txtar
-	for file, isCommented := range manifest.FileList {
-		if isCommented {
-			continue
-		}
-
-		// Only create files that aren't commented out
-		filePath := filepath.Join(projectInfo.ExtractDir, file)
-
-		// Create the directory structure
-		if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
-			return err
-		}
-
-		// Write some test content
-		if err := os.WriteFile(filePath, []byte("Mock content for "+file), 0o644); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func (m *MockArchiveProcessor) ProcessTxtarArchive(manifest Manifest, projectInfo ProjectInfo) error {
-	// Create a txtar archive with the files in the extract directory
-	var ar txtar.Archive
-
-	// Add the files that aren't commented out
-	for file, isCommented := range manifest.FileList {
-		if isCommented {
-			continue
-		}
-
-		ar.Files = append(ar.Files, txtar.File{
-			Name: file,
-			Data: []byte("Mock content for " + file),
-		})
-	}
-
-	// Format the archive
-	m.TxtarContent = txtar.Format(&ar)
-
-	// Write to the txtar file
-	return os.WriteFile(projectInfo.TxtarFile, m.TxtarContent, 0o644)
-}
-
 func TestWorkflow(t *testing.T) {
 	// Setup test logger
 	logger := zapr.NewLogger(zaptest.NewLogger(t))
 
-	// Create a temporary directory for our test
-	tempDir, err := os.MkdirTemp("", "nearwait_test")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Change to temp directory
-	originalDir, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("Failed to get current dir: %v", err)
-	}
-	defer os.Chdir(originalDir)
-
-	if err := os.Chdir(tempDir); err != nil {
-		t.Fatalf("Failed to change to temp dir: %v", err)
-	}
-
-	// Create mock files for testing
-	mockFiles := map[string]bool{
-		"go.mod":            true,
-		"main.go":           true,
-		"internal/util.go":  true,
-		"cmd/cli.go":        true,
-		"README.md":         true,
-		"testdata/test.txt": true,
-	}
-
-	// Create the necessary directory structure
-	for file := range mockFiles {
-		dir := filepath.Dir(file)
-		if dir != "." {
-			if err := os.MkdirAll(dir, 0o755); err != nil {
-				t.Fatalf("Failed to create directory %s: %v", dir, err)
-			}
-		}
-
-		// Create empty file
-		if err := os.WriteFile(file, []byte("test content"), 0o644); err != nil {
-			t.Fatalf("Failed to create file %s: %v", file, err)
-		}
+	// The project lives in memory, as does everything written from it
+	project := fstest.MapFS{
+		"go.mod":            {Data: []byte("module test\n")},
+		"main.go":           {Data: []byte("package main\n")},
+		"internal/util.go":  {Data: []byte("package internal\n")},
+		"cmd/cli.go":        {Data: []byte("package cmd\n")},
+		"README.md":         {Data: []byte("# test\n")},
+		"testdata/test.txt": {Data: []byte("test content\n")},
 	}
+	output := NewMemorySink()
 
-	// Step 1: Generate initial manifest with mocked file system walker
+	// Step 1: Generate initial manifest from the in-memory project
 	manifestFile := ".nearwait.yml"
 	generator := NewManifestGenerator(logger)
-	generator.WithFS(os.DirFS("."))
-
-	// Mock the file walker to return our predefined files
-	mockWalker := &MockFileSystemWalker{Files: mockFiles}
-	generator.walker = mockWalker
+	generator.WithFS(project)
 
 	// Override the manifest writer to capture the manifest content
 	mockWriter := &MockManifestWriter{}
 	generator.writer = mockWriter
+	generator.reader = mockWriter
 
 	isNew, err := generator.Generate(false, manifestFile)
 	if err != nil {
@@ -196,6 +95,9 @@ func TestWorkflow(t *testing.T) {
 	if !isNew {
 		t.Errorf("Expected new manifest to be created")
 	}
+	if len(mockWriter.ManifestData.FileList) != len(project) {
+		t.Errorf("Generated manifest lists %d files, want %d", len(mockWriter.ManifestData.FileList), len(project))
+	}
 
 	// Create a manifest with some files commented out
 	testManifest := Manifest{
@@ -214,23 +116,16 @@ func TestWorkflow(t *testing.T) {
 		t.Fatalf("Failed to write manifest file: %v", err)
 	}
 
-	// Step 3: Process the manifest with our mock processor
+	// Step 2: Process the manifest against the same file system
 	processor := NewManifestProcessor(logger, false, manifestFile)
-	mockArchiver := &MockArchiveProcessor{}
-	processor.archiver = mockArchiver
+	processor.WithFS(project)
+	processor.WithOutput(output)
 	processor.reader = mockWriter // Use our mock writer as reader too
 
 	// Use mock clipboard to avoid real clipboard operations
 	mockClipboard := &MockClipboard{}
 	processor.WithClipboard(mockClipboard)
 
-	// Create a basic txtarFile in the temp directory that will be read by the processor
-	txtarFile := filepath.Join(tempDir, ".nearwait.txtar")
-	dummyContent := []byte("-- go.mod --\nmodule test\n\ngo 1.20\n")
-	if err := os.WriteFile(txtarFile, dummyContent, 0o644); err != nil {
-		t.Fatalf("Failed to write dummy txtar file: %v", err)
-	}
-
 	isEmpty, err := processor.Process()
 	if err != nil {
 		t.Fatalf("Failed to process manifest: %v", err)
@@ -239,9 +134,23 @@ func TestWorkflow(t *testing.T) {
 		t.Errorf("Expected non-empty manifest result")
 	}
 
-	// Verify the mock processor was called correctly and clipboard was used
-	if mockClipboard.Content == "" {
-		t.Errorf("Expected clipboard to be used")
+	bundle, err := output.ReadFile(".nearwait.txtar")
+	if err != nil {
+		t.Fatalf("Failed to read bundle from output: %v", err)
+	}
+	if mockClipboard.Content != string(bundle) {
+		t.Errorf("Clipboard = %q, want the bundle %q", mockClipboard.Content, bundle)
+	}
+
+	var names []string
+	for _, f := range txtar.Parse(bundle).Files {
+		names = append(names, f.Name)
+		if want := string(project[f.Name].Data); string(f.Data) != want {
+			t.Errorf("Bundled %s = %q, want %q", f.Name, f.Data, want)
+		}
+	}
+	if want := []string{"go.mod", "internal/util.go", "main.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Bundled files = %v, want %v", names, want)
 	}
 
 	// Test an empty manifest
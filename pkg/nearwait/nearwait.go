@@ -0,0 +1,290 @@
+// Package nearwait bundles the files enabled in a nearwait manifest into a
+// txtar archive for programs that embed nearwait.
+//
+// Unlike the command, the library never reads from stdin, writes to stdout or
+// touches the clipboard; everything it produces is returned in a Result.
+//
+//	res, err := nearwait.Bundle(ctx, nearwait.Options{Dir: "/path/to/project"})
+//	if errors.Is(err, nearwait.ErrEmptyManifest) {
+//		// nothing enabled yet
+//	}
+package nearwait
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/tools/txtar"
+
+	"github.com/gkwa/nearwait/core"
+)
+
+// DefaultManifestFile is the manifest read when Options.ManifestFile is empty
+const DefaultManifestFile = ".nearwait.yml"
+
+var (
+	// ErrEmptyManifest is returned when the manifest enables no files
+	ErrEmptyManifest = core.ErrEmptyManifest
+	// ErrSecretsFound is returned when high-confidence secrets were redacted
+	// and Options.AllowSecrets is not set
+	ErrSecretsFound = core.ErrSecretsFound
+	// ErrOverBudget is returned when the archive holds more than
+	// Options.MaxTokens tokens
+	ErrOverBudget = core.ErrOverBudget
+)
+
+// The types below are those of the command, shared so that callers need not
+// import its internals
+type (
+	// OutputSink receives the archive and batches, see Options.Output
+	OutputSink = core.OutputSink
+	// MemorySink keeps the archive and batches in memory
+	MemorySink = core.MemorySink
+	// DirSink writes the archive and batches to a directory
+	DirSink = core.DirSink
+	// BinaryPolicy controls how binary files are rendered
+	BinaryPolicy = core.BinaryPolicy
+	// OrderStrategy controls the order of files in the bundle
+	OrderStrategy = core.OrderStrategy
+	// SecretFinding describes a redacted secret
+	SecretFinding = core.SecretFinding
+)
+
+// Binary policies
+const (
+	BinarySkip     = core.BinarySkip
+	BinaryBase64   = core.BinaryBase64
+	BinaryMetadata = core.BinaryMetadata
+)
+
+// File orders
+const (
+	OrderDefault  = core.OrderDefault
+	OrderLexical  = core.OrderLexical
+	OrderManifest = core.OrderManifest
+	OrderSize     = core.OrderSize
+	OrderDeps     = core.OrderDeps
+)
+
+// NewMemorySink returns an empty in-memory output
+func NewMemorySink() *MemorySink { return core.NewMemorySink() }
+
+// NewDirSink returns an output writing to dir
+func NewDirSink(dir string) *DirSink { return core.NewDirSink(dir) }
+
+// OpenSource serves a project archive or local container image as Options.FS,
+// like the command's --source flag. Links leading nowhere inside the source
+// are reported to logger.
+func OpenSource(logger logr.Logger, source string) (fs.FS, error) {
+	return core.OpenSourceFS(logger, source)
+}
+
+// Options configures a Bundle call. The zero value bundles the manifest in
+// the working directory with the command's defaults.
+type Options struct {
+	// Dir is the project directory; empty means the working directory. The
+	// manifest, go.mod, mounts and the git repository of Diff, History and
+	// Revisions are all resolved against it.
+	Dir string
+	// ManifestFile is the manifest to read, DefaultManifestFile by default.
+	// A relative path is resolved against Dir.
+	ManifestFile string
+	// FS holds the project files, such as an archive opened with OpenSource;
+	// nil reads Dir. Diff, History and Revisions need Dir.
+	FS fs.FS
+	// Output receives the txtar archive as well; nil keeps it in memory only
+	Output OutputSink
+	// Logger receives progress messages; the zero value discards them
+	Logger logr.Logger
+
+	// MaxTokens fails bundles estimated to hold more tokens; 0 means no budget
+	MaxTokens int
+	// BatchKBytes splits the bundle into batches of at most this many
+	// kilobytes; 0 disables batching
+	BatchKBytes int64
+	// Binary is how binary files are rendered, BinarySkip by default
+	Binary BinaryPolicy
+	// BinaryTypes overrides Binary per file extension, e.g. "png"
+	BinaryTypes map[string]BinaryPolicy
+	// NoRedact disables secret redaction
+	NoRedact bool
+	// AllowSecrets returns the bundle even when high-confidence secrets were
+	// redacted
+	AllowSecrets bool
+	// Outline renders Go files as outlines unless their entry says mode: full
+	Outline bool
+	// Order is the file order, OrderDefault by default
+	Order OrderStrategy
+	// DepAPI lists Go packages whose exported API is appended
+	DepAPI []string
+	// WithTests pairs enabled sources with their tests and the other way round
+	WithTests bool
+	// TestPatterns overrides the test file patterns of WithTests
+	TestPatterns []string
+	// Diff appends changes.diff against this git ref when set
+	Diff string
+	// DiffContext is the number of context lines in changes.diff, 3 by
+	// default; a negative value means none
+	DiffContext int
+	// DiffUntracked includes untracked files in changes.diff
+	DiffUntracked bool
+	// History appends history.txt with this many commits per file when set
+	History int
+	// HistoryBody includes commit message bodies in history.txt
+	HistoryBody bool
+	// HistoryKBytes caps the size of history.txt, 16 by default; a negative
+	// value means no limit
+	HistoryKBytes int
+	// Revisions reads the enabled files as of these git revisions
+	Revisions []string
+	// RevisionPrefix places each revision under a directory named after it
+	RevisionPrefix bool
+	// VirtualFiles are generated files, such as compiler output, appended to
+	// the end of the bundle. Their names may not match an enabled file or
+	// another generated file.
+	VirtualFiles []File
+}
+
+// File is a single section of the bundle
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Stats summarizes a bundle
+type Stats struct {
+	// Files is the number of sections in the archive
+	Files int
+	// Bytes is the size of the archive
+	Bytes int
+	// Batches is the number of batches, 0 without batching
+	Batches int
+	// Redacted is the number of redacted secrets
+	Redacted int
+}
+
+// Result is the bundle produced by Bundle
+type Result struct {
+	// Files lists the sections of the archive in order, with the contents
+	// they were rendered with
+	Files []File
+	// Archive is the txtar archive of every file
+	Archive []byte
+	// Batches holds the batch archives when Options.BatchKBytes is set
+	Batches [][]byte
+	// PairedTests lists the files added by Options.WithTests
+	PairedTests []string
+	// Findings lists the redacted secrets
+	Findings []SecretFinding
+	Stats    Stats
+}
+
+// Bundle reads the manifest and bundles its enabled files. It returns
+// ErrEmptyManifest when no file is enabled and ctx.Err() once ctx is done.
+func Bundle(ctx context.Context, opts Options) (*Result, error) {
+	processor, err := newProcessor(opts)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := processor.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ar := txtar.Parse(bundle.Archive)
+	if err := core.UnescapeArchive(ar); err != nil {
+		return nil, fmt.Errorf("error reading back the archive: %w", err)
+	}
+	res := &Result{
+		Archive:     bundle.Archive,
+		Batches:     bundle.Batches,
+		PairedTests: bundle.PairedTests,
+		Findings:    bundle.Findings,
+		Stats: Stats{
+			Files:    len(ar.Files),
+			Bytes:    len(bundle.Archive),
+			Batches:  len(bundle.Batches),
+			Redacted: len(bundle.Findings),
+		},
+	}
+	for _, f := range ar.Files {
+		res.Files = append(res.Files, File{Name: f.Name, Data: f.Data})
+	}
+	return res, nil
+}
+
+// newProcessor builds a ManifestProcessor configured from opts
+func newProcessor(opts Options) (*core.ManifestProcessor, error) {
+	for ext, policy := range opts.BinaryTypes {
+		if _, err := core.ParseBinaryPolicy(string(policy)); err != nil {
+			return nil, fmt.Errorf("invalid binary policy for %s: %w", ext, err)
+		}
+	}
+	if opts.Binary != "" {
+		if _, err := core.ParseBinaryPolicy(string(opts.Binary)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := core.ParseOrderStrategy(string(opts.Order)); err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger.GetSink() == nil {
+		logger = logr.Discard()
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	manifestFile := opts.ManifestFile
+	if manifestFile == "" {
+		manifestFile = DefaultManifestFile
+	}
+	if !filepath.IsAbs(manifestFile) {
+		manifestFile = filepath.Join(dir, manifestFile)
+	}
+	output := opts.Output
+	if output == nil {
+		output = core.NewMemorySink()
+	}
+
+	processor := core.NewManifestProcessor(logger, false, manifestFile)
+	processor.WithDir(dir)
+	processor.WithOutput(output)
+	processor.WithNoopClipboard()
+	processor.WithMaxTokens(opts.MaxTokens)
+	processor.WithBatchKBytes(opts.BatchKBytes)
+	processor.WithBinaryPolicy(opts.Binary, opts.BinaryTypes)
+	processor.WithRedaction(!opts.NoRedact, opts.AllowSecrets)
+	processor.WithOutline(opts.Outline)
+	processor.WithOrder(opts.Order)
+	processor.WithDepAPI(opts.DepAPI)
+	processor.WithTestPairing(opts.WithTests, opts.TestPatterns)
+	processor.WithDiff(opts.Diff, withDefault(opts.DiffContext, core.DefaultDiffContext), opts.DiffUntracked)
+	processor.WithHistory(opts.History, opts.HistoryBody, withDefault(opts.HistoryKBytes, core.DefaultHistoryKBytes)*1024)
+	processor.WithRevisions(opts.Revisions, opts.RevisionPrefix)
+	for _, f := range opts.VirtualFiles {
+		processor.WithVirtualFile(f.Name, f.Data)
+	}
+	if opts.FS != nil {
+		processor.WithFS(opts.FS)
+	}
+
+	return processor, nil
+}
+
+// withDefault maps the zero value of an option to the command's default and
+// negative values to 0
+func withDefault(value, def int) int {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 0
+	}
+	return value
+}
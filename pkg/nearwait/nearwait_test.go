@@ -0,0 +1,227 @@
+package nearwait
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap/zaptest"
+)
+
+// writeManifest writes a manifest enabling the given entries and returns its path
+func writeManifest(t *testing.T, manifest string) string {
+	t.Helper()
+	manifestFile := filepath.Join(t.TempDir(), ".nearwait.yml")
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return manifestFile
+}
+
+func TestBundle(t *testing.T) {
+	project := fstest.MapFS{
+		"go.mod":      {Data: []byte("module example.com/app\n")},
+		"main.go":     {Data: []byte("package main\n\n-- not a section --\n")},
+		"internal/x":  {Data: []byte("x\n")},
+		"config.env":  {Data: []byte("API_KEY=sk-abcdefghijklmnopqrstuvwxyz0123456789ABCD\n")},
+		"unlisted.go": {Data: []byte("package main\n")},
+	}
+
+	tests := []struct {
+		name        string
+		manifest    string
+		opts        Options
+		wantFiles   []string
+		wantBatches int
+		wantErr     error
+	}{
+		{
+			name:      "enabled files",
+			manifest:  "filelist:\n- go.mod\n- main.go\n# - internal/x\n",
+			wantFiles: []string{"go.mod", "main.go"},
+		},
+		{
+			name:        "batches",
+			manifest:    "filelist:\n- go.mod\n- main.go\n- internal/x\n",
+			opts:        Options{BatchKBytes: 1},
+			wantFiles:   []string{"go.mod", "internal/x", "main.go"},
+			wantBatches: 1,
+		},
+		{
+			name:      "virtual files",
+			manifest:  "filelist:\n- go.mod\n",
+			opts:      Options{VirtualFiles: []File{{Name: "build.log", Data: []byte("ok\n")}}},
+			wantFiles: []string{"go.mod", "build.log"},
+		},
+		{
+			name:     "empty manifest",
+			manifest: "filelist:\n# - go.mod\n",
+			wantErr:  ErrEmptyManifest,
+		},
+		{
+			name:     "secrets",
+			manifest: "filelist:\n- config.env\n",
+			wantErr:  ErrSecretsFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.ManifestFile = writeManifest(t, tt.manifest)
+			opts.FS = project
+			opts.Logger = zapr.NewLogger(zaptest.NewLogger(t))
+
+			res, err := Bundle(context.Background(), opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Bundle() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Bundle() error = %v", err)
+			}
+
+			var names []string
+			for _, f := range res.Files {
+				names = append(names, f.Name)
+				// Sections are returned unescaped, as the files read
+				if file, ok := project[f.Name]; ok && string(f.Data) != string(file.Data) {
+					t.Errorf("%s = %q, want %q", f.Name, f.Data, file.Data)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.wantFiles) {
+				t.Errorf("Files = %v, want %v", names, tt.wantFiles)
+			}
+			if len(res.Batches) != tt.wantBatches || res.Stats.Batches != tt.wantBatches {
+				t.Errorf("Batches = %d, Stats.Batches = %d, want %d", len(res.Batches), res.Stats.Batches, tt.wantBatches)
+			}
+			if res.Stats.Files != len(tt.wantFiles) || res.Stats.Bytes != len(res.Archive) {
+				t.Errorf("Stats = %+v, want %d files of %d bytes", res.Stats, len(tt.wantFiles), len(res.Archive))
+			}
+		})
+	}
+}
+
+func TestBundleDir(t *testing.T) {
+	root := t.TempDir()
+	proj := filepath.Join(root, "proj")
+	files := map[string]string{
+		"proj/go.mod":       "module example.com/app\n",
+		"proj/main.go":      "package main\n\nimport _ \"example.com/app/core\"\n",
+		"proj/core/a.go":    "package core\n",
+		"shared-lib/lib.go": "package shared\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, err := git.PlainInit(proj, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := worktree.AddGlob("."); err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := worktree.Commit("initial", &git.CommitOptions{Author: signature}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(proj, "core", "a.go"), []byte("package core\n\n// changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The manifest, its absolute entry, the mount, go.mod and the diff are
+	// all resolved against Dir rather than the working directory
+	manifest := "mounts: {shared: ../shared-lib}\nfilelist:\n- " + filepath.Join(proj, "go.mod") + "\n- main.go\n- core/a.go\n- shared/lib.go\n"
+	if err := os.WriteFile(filepath.Join(proj, DefaultManifestFile), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Bundle(context.Background(), Options{Dir: proj, Order: OrderDeps, Diff: "HEAD"})
+	if err != nil {
+		t.Fatalf("Bundle() error = %v", err)
+	}
+
+	var names []string
+	for _, f := range res.Files {
+		names = append(names, f.Name)
+	}
+	if want := []string{"go.mod", "core/a.go", "shared/lib.go", "main.go", "changes.diff"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Files = %v, want %v", names, want)
+	}
+	if diff := res.Files[len(res.Files)-1].Data; !strings.Contains(string(diff), "+// changed") {
+		t.Errorf("changes.diff = %q, want the change to core/a.go", diff)
+	}
+}
+
+func TestBundleOutput(t *testing.T) {
+	manifestFile := writeManifest(t, "filelist:\n- go.mod\n")
+	output := NewMemorySink()
+	res, err := Bundle(context.Background(), Options{
+		ManifestFile: manifestFile,
+		FS:           fstest.MapFS{"go.mod": {Data: []byte("module example.com/app\n")}},
+		Output:       output,
+	})
+	if err != nil {
+		t.Fatalf("Bundle() error = %v", err)
+	}
+	data, err := output.ReadFile(".nearwait.txtar")
+	if err != nil || string(data) != string(res.Archive) {
+		t.Errorf("Output .nearwait.txtar = %q, %v, want the archive", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(manifestFile), ".nearwait.txtar")); !os.IsNotExist(err) {
+		t.Errorf("Bundle() wrote the archive next to the manifest, Stat() error = %v", err)
+	}
+}
+
+func TestBundleCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Bundle(ctx, Options{
+		ManifestFile: writeManifest(t, "filelist:\n- go.mod\n"),
+		FS:           fstest.MapFS{"go.mod": {Data: []byte("module example.com/app\n")}},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Bundle() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestBundleInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Binary: "gzip"},
+		{BinaryTypes: map[string]BinaryPolicy{"png": "gzip"}},
+		{Order: "random"},
+	} {
+		if _, err := Bundle(context.Background(), opts); err == nil {
+			t.Errorf("Bundle(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+func TestWithDefault(t *testing.T) {
+	for _, tt := range []struct{ value, want int }{{0, 3}, {5, 5}, {-1, 0}} {
+		if got := withDefault(tt.value, 3); got != tt.want {
+			t.Errorf("withDefault(%d, 3) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}